@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/app"
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/index"
+)
+
+// runBatch implements `loam-iiif batch [flags] <collection-url>`.
+func runBatch(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	prompt := fs.String("prompt", "", "Prompt template rendered for each manifest (or .Input when --template is set)")
+	templateName := fs.String("template", "", "Name of a prompt template to render for each manifest")
+	output := fs.String("output", "", "JSONL file to append results to; an existing file is resumed (default stdout)")
+	concurrency := fs.Int("concurrency", 4, "Number of manifests to prompt in parallel")
+	rate := fs.Float64("rate", 0, "Maximum model requests per second (0 for no limit)")
+	provider := fs.String("provider", cfg.Chat.Provider, "Chat provider: bedrock, or mock for offline scripted replies")
+	mockScript := fs.String("mock-script", cfg.Chat.MockScript, "JSON script of replies for the mock provider (optional)")
+	model := fs.String("model", cfg.Chat.Model, "Bedrock model id or inference profile id")
+	profile := fs.String("profile", cfg.Bedrock.Profile, "AWS profile to use (optional)")
+	region := fs.String("region", cfg.Bedrock.Region, "AWS region for Bedrock (optional)")
+	endpointURL := fs.String("endpoint-url", cfg.Bedrock.EndpointURL, "Custom Bedrock Runtime endpoint URL (optional)")
+	systemPrompt := fs.String("system-prompt", cfg.Chat.SystemPrompt, "System prompt sent before each manifest's context")
+	contextTokens := fs.Int("context-tokens", cfg.Context.TokenBudget, "Approximate token budget for each manifest's context (0 for unlimited)")
+	contextFields := fs.String("context-fields", strings.Join(cfg.Context.Fields, ","), "Comma-separated resource fields to include in context")
+	maxToolSteps := fs.Int("max-tool-steps", cfg.Chat.MaxToolSteps, "Maximum rounds of tool calls per manifest (0 disables tools)")
+	maxTokens := fs.Int("max-tokens", cfg.Chat.Inference.MaxTokens, "Maximum tokens in each reply")
+	fs.Func("temperature", "Sampling temperature, 0-1 (defaults to the model's)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		cfg.Chat.Inference.Temperature = &v
+		return err
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: loam-iiif batch [flags] <collection-url>\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one collection URL")
+	}
+	if *prompt == "" && *templateName == "" {
+		fs.Usage()
+		return fmt.Errorf("one of --prompt or --template is required")
+	}
+	collectionURL := fs.Arg(0)
+
+	cfg.Chat.Provider = *provider
+	cfg.Chat.MockScript = *mockScript
+	cfg.Chat.Model = *model
+	cfg.Bedrock.Profile = *profile
+	cfg.Bedrock.Region = *region
+	cfg.Bedrock.EndpointURL = *endpointURL
+	cfg.Chat.SystemPrompt = *systemPrompt
+	cfg.Chat.MaxToolSteps = *maxToolSteps
+	cfg.Chat.Inference.MaxTokens = *maxTokens
+	cfg.Context.TokenBudget = *contextTokens
+	cfg.Context.Fields = splitList(*contextFields)
+
+	// With --template, --prompt is passed in as .Input; on its own it is the template
+	opts := app.BatchOptions{
+		Template:    app.PromptTemplate{Name: "prompt", Text: *prompt},
+		Concurrency: *concurrency,
+		Rate:        *rate,
+		Context: app.ContextBuilder{
+			Fields:      cfg.Context.Fields,
+			TokenBudget: cfg.Context.TokenBudget,
+		},
+	}
+	if *templateName != "" {
+		tmpl, err := app.FindTemplate(*templateName)
+		if err != nil {
+			return err
+		}
+		opts.Template = tmpl
+		opts.Input = *prompt
+	}
+
+	chatService, err := app.NewChatServiceFromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize chat service: %w", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, done, err := app.OpenBatchOutput(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+		opts.Skip = done
+	}
+
+	// Ctrl+C stops new requests; results already in flight are still written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Crawling %s...\n", collectionURL)
+	_, manifests, err := index.Crawl(ctx, collectionURL)
+	if err != nil {
+		return fmt.Errorf("failed to crawl collection: %w", err)
+	}
+	if len(opts.Skip) > 0 {
+		fmt.Fprintf(os.Stderr, "Resuming: %d of %d manifests already have results\n", len(opts.Skip), len(manifests))
+	}
+
+	failed := 0
+	write := app.BatchWriter(out)
+	opts.Progress = func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rPrompted %d/%d manifests", done, total)
+	}
+	err = app.RunBatch(ctx, chatService, manifests, opts, func(result app.BatchResult) error {
+		if result.Error != "" {
+			failed++
+		}
+		return write(result)
+	})
+	fmt.Fprintln(os.Stderr)
+	if errors.Is(err, context.Canceled) {
+		if *output != "" {
+			return fmt.Errorf("interrupted; run the same command again to resume")
+		}
+		return fmt.Errorf("interrupted")
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d manifests failed; their errors are in the output\n", failed)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bmquinn/loam-iiif/internal/bookmarks"
+)
+
+// runBookmarks implements `loam-iiif bookmarks list|export|import`.
+func runBookmarks(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: loam-iiif bookmarks list\n"+
+			"       loam-iiif bookmarks export [--folder name] [--iiif] [--id url] [--output file]\n"+
+			"       loam-iiif bookmarks import <file>\n")
+	}
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("missing bookmarks command")
+	}
+
+	path, err := bookmarks.DefaultPath()
+	if err != nil {
+		return err
+	}
+	b, err := bookmarks.Load(path)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, f := range b.Folders {
+			fmt.Fprintf(tw, "%s\t\t\n", f.Name)
+			for _, bm := range f.Bookmarks {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\n", bm.Title, bm.Type, bm.ID())
+			}
+		}
+		return tw.Flush()
+
+	case "export":
+		fs := flag.NewFlagSet("bookmarks export", flag.ExitOnError)
+		folder := fs.String("folder", "", "Export only this folder")
+		asIIIF := fs.Bool("iiif", false, "Export as a IIIF Presentation 3 collection instead of the bookmarks file format")
+		id := fs.String("id", "urn:loam-iiif:bookmarks", "The id of the exported IIIF collection")
+		output := fs.String("output", "", "File to write (defaults to stdout)")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: loam-iiif bookmarks export [flags]\n\n")
+			fs.PrintDefaults()
+		}
+		_ = fs.Parse(args[1:])
+
+		var data []byte
+		switch {
+		case *asIIIF:
+			data, err = b.Collection(*id, *folder)
+		case *folder != "":
+			f := b.Folder(*folder)
+			if f == nil {
+				return fmt.Errorf("no folder %q", *folder)
+			}
+			data, err = json.MarshalIndent(bookmarks.Bookmarks{Folders: []bookmarks.Folder{*f}}, "", "  ")
+		default:
+			data, err = json.MarshalIndent(b, "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if *output == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(*output, data, 0o644)
+
+	case "import":
+		if len(args) != 2 {
+			usage()
+			return fmt.Errorf("import needs one bookmarks file")
+		}
+		if _, err := os.Stat(args[1]); err != nil {
+			return err
+		}
+		other, err := bookmarks.Load(args[1])
+		if err != nil {
+			return err
+		}
+		added := b.Merge(other)
+		if err := b.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d bookmarks into %s\n", added, path)
+		return nil
+	}
+
+	usage()
+	return fmt.Errorf("unknown bookmarks command %q", args[0])
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bmquinn/loam-iiif/internal/app"
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/index"
+)
+
+// runIndex implements `loam-iiif index [flags] <collection-url>`.
+func runIndex(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	provider := fs.String("provider", cfg.Embeddings.Provider, "Embeddings provider: bedrock or openai")
+	model := fs.String("model", cfg.Embeddings.Model, "Embedding model id (defaults to the provider's standard model)")
+	endpoint := fs.String("endpoint", cfg.Embeddings.Endpoint, "Base URL of an OpenAI-compatible embeddings API")
+	dimensions := fs.Int("dimensions", cfg.Embeddings.Dimensions, "Embedding dimensions, if the model supports choosing")
+	profile := fs.String("profile", cfg.Bedrock.Profile, "AWS profile to use (optional)")
+	region := fs.String("region", cfg.Bedrock.Region, "AWS region for Bedrock embeddings (optional)")
+	endpointURL := fs.String("endpoint-url", cfg.Bedrock.EndpointURL, "Custom Bedrock Runtime endpoint URL (optional)")
+	concurrency := fs.Int("concurrency", 8, "Number of manifests to fetch in parallel")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: loam-iiif index [flags] <collection-url>\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one collection URL")
+	}
+	collectionURL := fs.Arg(0)
+
+	cfg.Embeddings.Provider = *provider
+	cfg.Embeddings.Model = *model
+	cfg.Embeddings.Endpoint = *endpoint
+	cfg.Embeddings.Dimensions = *dimensions
+	cfg.Bedrock.Profile = *profile
+	cfg.Bedrock.Region = *region
+	cfg.Bedrock.EndpointURL = *endpointURL
+
+	embedder, err := app.NewEmbedder(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Crawling %s...\n", collectionURL)
+	ix, err := index.Build(context.Background(), collectionURL, embedder, index.BuildOptions{
+		Concurrency: *concurrency,
+		Progress: func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rEmbedded %d/%d manifests", done, total)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to build index: %w", err)
+	}
+
+	if err := ix.Save(); err != nil {
+		return err
+	}
+	path, _ := index.PathFor(ix.Collection)
+	fmt.Printf("Indexed %d manifests from %s with %s/%s\n%s\n", len(ix.Entries), ix.Collection, ix.Provider, ix.Model, path)
+	return nil
+}
@@ -1,28 +1,123 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/bmquinn/loam-iiif/internal/app"
+	"github.com/bmquinn/loam-iiif/internal/config"
 	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/index"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Warning: %v (using defaults)", err)
+	}
+
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "index":
+			if err := runIndex(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			return
+		case "batch":
+			if err := runBatch(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			return
+		case "usage":
+			if err := runUsage(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			return
+		case "bookmarks":
+			if err := runBookmarks(os.Args[2:]); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			return
+		}
+	}
+
 	// Define command-line flags
 	manifestURL := flag.String("manifest", "", "IIIF manifest URL")
 	prompt := flag.String("prompt", "", "Prompt to send to the model")
-	profile := flag.String("profile", "", "AWS profile to use (optional)")
+	profile := flag.String("profile", cfg.Bedrock.Profile, "AWS profile to use (optional)")
+	region := flag.String("region", cfg.Bedrock.Region, "AWS region for Bedrock (defaults to the AWS environment, then us-east-1)")
+	endpointURL := flag.String("endpoint-url", cfg.Bedrock.EndpointURL, "Custom Bedrock Runtime endpoint URL, e.g. a VPC endpoint (optional)")
+	controlEndpointURL := flag.String("control-endpoint-url", cfg.Bedrock.ControlEndpointURL, "Custom Bedrock API endpoint URL, used to list models (optional)")
+	model := flag.String("model", cfg.Chat.Model, "Bedrock model id or inference profile id for chat, e.g. us.amazon.nova-pro-v1:0")
+	contextTokens := flag.Int("context-tokens", cfg.Context.TokenBudget, "Approximate token budget for chat context (0 for unlimited)")
+	contextFields := flag.String("context-fields", strings.Join(cfg.Context.Fields, ","), "Comma-separated resource fields to include in chat context")
+	maxToolSteps := flag.Int("max-tool-steps", cfg.Chat.MaxToolSteps, "Maximum rounds of tool calls per prompt (0 disables tools)")
+	systemPrompt := flag.String("system-prompt", cfg.Chat.SystemPrompt, "System prompt sent before the chat context")
+	templateName := flag.String("template", "", "Name of a prompt template to render (--prompt becomes its .Input)")
+	provider := flag.String("provider", cfg.Chat.Provider, "Chat provider: bedrock, or mock for offline scripted replies")
+	mockScript := flag.String("mock-script", cfg.Chat.MockScript, "JSON script of replies for the mock provider (optional)")
+	maxTokens := flag.Int("max-tokens", cfg.Chat.Inference.MaxTokens, "Maximum tokens in each reply")
+	flag.Func("temperature", "Sampling temperature, 0-1 (defaults to the model's)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		cfg.Chat.Inference.Temperature = &v
+		return err
+	})
+	flag.Func("top-p", "Nucleus sampling probability, 0-1 (defaults to the model's)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		cfg.Chat.Inference.TopP = &v
+		return err
+	})
+	flag.Func("top-k", "Sample from the k most likely tokens (defaults to the model's)", func(s string) error {
+		v, err := strconv.Atoi(s)
+		cfg.Chat.Inference.TopK = &v
+		return err
+	})
+	schemaName := flag.String("schema", "", "Answer with JSON matching a schema: a built-in name (metadata-suggestions, transcription), a name from the schemas config dir, or a file path")
+	schemaRetries := flag.Int("schema-retries", 2, "How many times to ask the model to correct an answer that does not match --schema")
+	theme := flag.String("theme", cfg.UI.Theme, "Color theme: auto, dark, light, high-contrast, or the name of a theme file")
+	mouse := flag.Bool("mouse", cfg.UI.Mouse, "Capture the mouse to scroll and click (toggle with alt+m while running)")
+	// Stop sequences may contain commas, so each one is a flag of its own.
+	// Any given on the command line replace those in config.json.
+	var stops []string
+	flag.Func("stop", "Stop sequence; repeat the flag for more than one", func(s string) error {
+		stops = append(stops, s)
+		return nil
+	})
 	flag.Parse()
 
-	// Check if both --manifest and --prompt are provided
-	if *manifestURL != "" && *prompt != "" {
+	cfg.Chat.Inference.MaxTokens = *maxTokens
+	if stops != nil {
+		cfg.Chat.Inference.StopSequences = stops
+	}
+
+	cfg.Chat.Provider = *provider
+	cfg.Chat.Model = *model
+	cfg.Bedrock.Profile = *profile
+	cfg.Bedrock.Region = *region
+	cfg.Bedrock.EndpointURL = *endpointURL
+	cfg.Bedrock.ControlEndpointURL = *controlEndpointURL
+	cfg.Chat.MockScript = *mockScript
+
+	cfg.UI.Theme = *theme
+	cfg.UI.Mouse = *mouse
+
+	cfg.Chat.MaxToolSteps = *maxToolSteps
+	cfg.Chat.SystemPrompt = *systemPrompt
+	cfg.Context.TokenBudget = *contextTokens
+	cfg.Context.Fields = splitList(*contextFields)
+
+	// Check if --manifest is provided with --prompt or --template
+	if *manifestURL != "" && (*prompt != "" || *templateName != "") {
 		// Run in command-line mode
-		response, err := runCommandLine(*manifestURL, *prompt, *profile)
+		response, err := runCommandLine(cfg, *manifestURL, *prompt, *templateName, *schemaName, *schemaRetries)
 		if err != nil {
 			log.Fatalf("Error: %v", err)
 		}
@@ -31,15 +126,25 @@ func main() {
 	}
 
 	// Otherwise, launch the TUI
-	p := tea.NewProgram(app.InitialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if cfg.UI.Mouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(app.InitialModel(cfg), options...)
+	final, err := p.Run()
+	if err != nil {
 		log.Fatal(err)
 		os.Exit(1)
 	}
+	if m, ok := final.(*app.Model); ok {
+		if err := m.SaveTabs(); err != nil {
+			log.Printf("Warning: failed to save tabs: %v", err)
+		}
+	}
 }
 
 // runCommandLine handles the command-line operation
-func runCommandLine(manifestURL, prompt, profile string) (string, error) {
+func runCommandLine(cfg config.Config, manifestURL, prompt, templateName, schemaName string, schemaRetries int) (string, error) {
 	// Step 1: Fetch the IIIF manifest
 	data, err := iiif.FetchDataSync(manifestURL)
 	if err != nil {
@@ -51,25 +156,79 @@ func runCommandLine(manifestURL, prompt, profile string) (string, error) {
 	if len(items) == 0 {
 		return "", fmt.Errorf("no items found in the manifest")
 	}
+	res, err := iiif.ParseResource(data)
+	if err != nil {
+		return "", err
+	}
+
+	// Render the prompt template, if one was chosen
+	if templateName != "" {
+		tmpl, err := app.FindTemplate(templateName)
+		if err != nil {
+			return "", err
+		}
+		prompt, err = tmpl.Render(app.TemplateData{Resource: res, Items: items, Input: prompt})
+		if err != nil {
+			return "", err
+		}
+	}
 
-	// Step 3: Extract context from the parsed items
-	var contextBuilder strings.Builder
-	for _, item := range items {
-		contextBuilder.WriteString(fmt.Sprintf("Title: %s\nURL: %s\n\n", item.Title, item.URL))
+	// Step 3: Build context from the resource and its items
+	builder := app.ContextBuilder{
+		Fields:      cfg.Context.Fields,
+		TokenBudget: cfg.Context.TokenBudget,
 	}
-	context := contextBuilder.String()
 
 	// Step 4: Initialize the ChatService
-	chatService, err := app.NewChatService(profile)
+	chatService, err := app.NewChatServiceFromConfig(cfg)
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize chat service: %w", err)
 	}
 
-	// Step 5: Send the prompt and get the response
-	response, err := chatService.SendChatSync(prompt, context)
+	// Add manifests related to the prompt if the collection has been indexed
+	var hits []index.Hit
+	if ix, err := index.Load(res.ID); err == nil {
+		hits, err = app.SearchIndex(ix, cfg, prompt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: semantic retrieval failed: %v\n", err)
+		}
+	}
+	context := builder.BuildWithHits([]*iiif.Resource{res}, items, hits)
+
+	// Step 5: Send the prompt and get the response, logging tool calls to stderr
+	logTool := func(use app.ToolUse, result string) {
+		fmt.Fprintf(os.Stderr, "→ %s %s\n← %d bytes\n", use.Name, use.Input, len(result))
+	}
+	if schemaName != "" {
+		schema, err := app.LoadSchema(schemaName)
+		if err != nil {
+			return "", err
+		}
+		answer, err := chatService.SendStructuredSync(prompt, context, schema, schemaRetries, logTool)
+		if err != nil {
+			return "", fmt.Errorf("failed to get structured answer: %w", err)
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, answer, "", "  "); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+	response, err := chatService.SendChatSync(prompt, context, logTool)
 	if err != nil {
 		return "", fmt.Errorf("failed to send prompt: %w", err)
 	}
 
 	return response, nil
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
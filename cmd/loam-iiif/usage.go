@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/usage"
+)
+
+// runUsage implements `loam-iiif usage [flags]`.
+func runUsage(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	days := fs.Int("days", 30, "Summarize the last n days (0 for all time)")
+	pricesFile := fs.String("prices", "", `JSON file of per-model prices, e.g. {"amazon.nova-lite-v1:0": {"input_per_1k": 0.00006, "output_per_1k": 0.00024}}`)
+	logPath := fs.String("log", "", "Usage log to read (defaults to usage.jsonl in the config directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: loam-iiif usage [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	prices := make(map[string]config.Price)
+	for model, price := range cfg.Usage.Prices {
+		prices[model] = price
+	}
+	if *pricesFile != "" {
+		data, err := os.ReadFile(*pricesFile)
+		if err != nil {
+			return fmt.Errorf("failed to read prices: %w", err)
+		}
+		var filePrices map[string]config.Price
+		if err := json.Unmarshal(data, &filePrices); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", *pricesFile, err)
+		}
+		for model, price := range filePrices {
+			prices[model] = price
+		}
+	}
+
+	path := *logPath
+	if path == "" {
+		var err error
+		if path, err = usage.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	records, err := usage.Load(path)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *days > 0 {
+		now := time.Now()
+		since = time.Date(now.Year(), now.Month(), now.Day()-*days+1, 0, 0, 0, 0, time.Local)
+	}
+	summaries := usage.Summarize(records, since, prices)
+	if len(summaries) == 0 {
+		fmt.Printf("No usage recorded in %s\n", path)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Day\tModel\tRequests\tInput\tOutput\tAvg latency\tCost\t")
+	totals := make(map[string]*usage.Summary)
+	for _, s := range summaries {
+		printSummary(w, s.Day, s)
+
+		t, ok := totals[s.Model]
+		if !ok {
+			t = &usage.Summary{Model: s.Model, Priced: true}
+			totals[s.Model] = t
+		}
+		t.Requests += s.Requests
+		t.InputTokens += s.InputTokens
+		t.OutputTokens += s.OutputTokens
+		t.Estimated += s.Estimated
+		t.LatencyMS += s.LatencyMS
+		t.Cost += s.Cost
+		t.Priced = t.Priced && s.Priced
+	}
+
+	models := make([]string, 0, len(totals))
+	for model := range totals {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	fmt.Fprintln(w, "\t\t\t\t\t\t\t")
+	for _, model := range models {
+		printSummary(w, "Total", *totals[model])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	estimated := 0
+	for _, t := range totals {
+		estimated += t.Estimated
+	}
+	if estimated > 0 {
+		fmt.Printf("\nToken counts for %d requests were estimated because the provider did not report them.\n", estimated)
+	}
+	return nil
+}
+
+func printSummary(w *tabwriter.Writer, day string, s usage.Summary) {
+	cost := "-"
+	if s.Priced {
+		cost = fmt.Sprintf("$%.4f", s.Cost)
+	}
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%dms\t%s\t\n",
+		day, s.Model, s.Requests, s.InputTokens, s.OutputTokens, s.LatencyMS/int64(s.Requests), cost)
+}
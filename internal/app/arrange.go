@@ -0,0 +1,152 @@
+// File: /loam/internal/app/arrange.go
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// itemDetails are what sorting needs to know about a fetched manifest or
+// collection that its listing in a collection may leave out.
+type itemDetails struct {
+	NavDate  string
+	Canvases int
+}
+
+// arrangement is how the list being shown is sorted and grouped. Lists
+// without a URL, such as search results, share one.
+func (m *Model) arrangement() ui.Arrangement {
+	return m.Arrangements[m.Level.URL]
+}
+
+// arrangeItems sorts and groups items for the list at url.
+func (m *Model) arrangeItems(url string, items []list.Item) []list.Item {
+	a := m.Arrangements[url]
+	if a.IsDefault() {
+		return items
+	}
+	return ui.Arrange(items, a)
+}
+
+// arrangeList sorts and groups the list being shown as remembered for its
+// URL, keeping the selected item.
+func (m *Model) arrangeList() tea.Cmd {
+	m.applyMetadata()
+	selected, _ := m.List.SelectedItem().(ui.Item)
+	cmd := m.List.SetItems(ui.Arrange(m.List.Items(), m.arrangement()))
+	if m.List.FilterState() == list.Unfiltered {
+		for i, li := range m.List.Items() {
+			if item, ok := li.(ui.Item); ok && item.URL == selected.URL {
+				m.List.Select(i)
+				break
+			}
+		}
+	}
+	m.skipHeaders(false)
+	return cmd
+}
+
+// cycleSort switches the list being shown to the next sort mode.
+func (m *Model) cycleSort() tea.Cmd {
+	a := m.arrangement()
+	a.Sort = ui.Next(ui.SortModes, a.Sort)
+	return m.setArrangement(a)
+}
+
+// cycleGroup switches the list being shown to the next way of grouping.
+func (m *Model) cycleGroup() tea.Cmd {
+	a := m.arrangement()
+	a.Group = ui.Next(ui.GroupModes, a.Group)
+	return m.setArrangement(a)
+}
+
+func (m *Model) setArrangement(a ui.Arrangement) tea.Cmd {
+	if a.IsDefault() {
+		delete(m.Arrangements, m.Level.URL)
+	} else {
+		m.Arrangements[m.Level.URL] = a
+	}
+	cmd := m.arrangeList()
+	m.Status = describeArrangement(a)
+	if err := saveArrangements(m.Arrangements); err != nil {
+		m.Status += " (not saved: " + err.Error() + ")"
+	}
+	return cmd
+}
+
+func describeArrangement(a ui.Arrangement) string {
+	s := "Sorted by " + string(ui.SortOriginal) + " order"
+	if a.Sort != "" && a.Sort != ui.SortOriginal {
+		s = "Sorted by " + string(a.Sort)
+	}
+	if a.Group != "" && a.Group != ui.GroupNone {
+		s += ", grouped by " + string(a.Group)
+	}
+	return s
+}
+
+// skipHeaders moves the selection off a section header, up or down.
+func (m *Model) skipHeaders(up bool) {
+	if _, ok := m.List.SelectedItem().(ui.Header); !ok {
+		return
+	}
+	if up && m.List.Index() > 0 {
+		m.List.CursorUp()
+	} else {
+		m.List.CursorDown()
+	}
+}
+
+// cacheDetails records the navDate and canvases of a fetched resource for
+// sorting.
+func (m *Model) cacheDetails(res *iiif.Resource) {
+	m.ItemDetails[res.ID] = itemDetails{NavDate: res.NavDate, Canvases: len(res.Canvases)}
+}
+
+func arrangementsPath() (string, error) {
+	return config.Path("sorting.json")
+}
+
+// loadArrangements reads the arrangements saved by earlier runs. If they
+// can't be read, the lists start in their original order.
+func loadArrangements() map[string]ui.Arrangement {
+	arrangements := make(map[string]ui.Arrangement)
+	path, err := arrangementsPath()
+	if err != nil {
+		return arrangements
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return arrangements
+	}
+	if json.Unmarshal(data, &arrangements) != nil {
+		return make(map[string]ui.Arrangement)
+	}
+	return arrangements
+}
+
+// saveArrangements writes how each list is sorted and grouped to
+// sorting.json in the config directory.
+func saveArrangements(arrangements map[string]ui.Arrangement) error {
+	path, err := arrangementsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(arrangements, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sorting: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,200 @@
+// File: /loam/internal/app/backend.go
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/bmquinn/loam-iiif/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// setupCheckTimeout bounds the request made to check the chat backend.
+const setupCheckTimeout = 20 * time.Second
+
+// ChatSetupMsg reports whether the chat backend is usable. When it is not,
+// Problem says what is wrong and Hint what to do about it.
+type ChatSetupMsg struct {
+	Ready   bool
+	ModelID string
+	Region  string
+	Models  []string
+	Problem string
+	Hint    string
+	Err     error
+}
+
+// chatBackend creates the chat service on first use, so AWS configuration is
+// only loaded by users who chat, and remembers a failure until it is reset.
+type chatBackend struct {
+	mu        sync.Mutex
+	cfg       config.Config
+	attempted bool
+	service   *ChatService
+	err       error
+}
+
+var backend chatBackend
+
+// ConfigureChat sets how the chat service is created on first use.
+func ConfigureChat(cfg config.Config) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.cfg = cfg
+	backend.attempted = false
+	backend.service = nil
+	backend.err = nil
+}
+
+// chatService returns the chat service, creating it on the first call.
+func chatService() (*ChatService, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if !backend.attempted {
+		backend.service, backend.err = NewChatServiceFromConfig(backend.cfg)
+		backend.attempted = true
+	}
+	return backend.service, backend.err
+}
+
+// embeddingsConfig returns the configuration the chat service is created
+// with, whose AWS and usage settings embeddings share, with cfg as its
+// embeddings settings.
+func embeddingsConfig(cfg config.EmbeddingsConfig) config.Config {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	c := backend.cfg
+	c.Embeddings = cfg
+	return c
+}
+
+// resetChatService discards the chat service so the next use creates it again.
+func resetChatService() {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.attempted = false
+	backend.service = nil
+	backend.err = nil
+}
+
+// CheckChatSetup creates the chat service if needed and checks that the
+// provider can be reached by listing its models.
+func CheckChatSetup() tea.Cmd {
+	return func() tea.Msg {
+		cs, err := chatService()
+		if err != nil {
+			problem, hint := DiagnoseChatError(err)
+			return ChatSetupMsg{Problem: problem, Hint: hint, Err: err}
+		}
+
+		msg := ChatSetupMsg{ModelID: cs.Provider.ModelID()}
+		if bp, ok := cs.Provider.(*BedrockProvider); ok {
+			msg.Region = bp.Region
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), setupCheckTimeout)
+		defer cancel()
+		models, err := cs.Provider.Models(ctx)
+		if err != nil {
+			msg.Problem, msg.Hint = DiagnoseChatError(err)
+			msg.Err = err
+			return msg
+		}
+		msg.Ready = true
+		msg.Models = models
+		return msg
+	}
+}
+
+// DiagnoseChatError explains a chat backend error as a short problem and a
+// hint on how to fix it.
+func DiagnoseChatError(err error) (problem, hint string) {
+	msg := err.Error()
+	code := ""
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+
+	switch {
+	case errors.Is(err, errUnsupportedModel):
+		return "Model not supported",
+			"Choose an Amazon Nova model with --model, LOAM_CHAT_MODEL or chat.model."
+	case strings.Contains(msg, "SSO") || code == "ExpiredTokenException":
+		return "AWS session expired",
+			"Run `aws sso login` (with --profile if you use one)."
+	case strings.Contains(msg, "get credentials") || strings.Contains(msg, "failed to retrieve credentials"):
+		return "No AWS credentials found",
+			"Configure credentials with `aws configure sso` or set AWS_PROFILE."
+	case code == "AccessDeniedException":
+		return "No access to Bedrock",
+			"Your AWS identity needs Bedrock permissions, and model access must be enabled in the Bedrock console."
+	case code == "UnrecognizedClientException":
+		return "Region not enabled",
+			"The AWS region rejected your credentials. Enable the region for your account or choose another."
+	case strings.Contains(msg, "no such host"):
+		return "Bedrock not available in this region",
+			"Choose a region where Bedrock is offered."
+	case code == "ResourceNotFoundException" || (code == "ValidationException" && aboutModelID(msg)):
+		return "Model not available",
+			"Check the model id and that access to it is enabled in this region."
+	}
+	return "Chat backend unavailable", fmt.Sprintf("%v", err)
+}
+
+// aboutModelID reports whether a validation error message is about the model
+// identifier, rather than about something in a single request such as its
+// length or stop sequences.
+func aboutModelID(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "model identifier") || strings.Contains(msg, "model id")
+}
+
+// setupProblem reports whether err is one DiagnoseChatError recognizes as a
+// setup problem, as opposed to a failure of a single request.
+func setupProblem(err error) bool {
+	problem, _ := DiagnoseChatError(err)
+	return problem != "Chat backend unavailable"
+}
+
+// checkChatSetup starts a check of the chat backend and shows it as pending.
+func (m *Model) checkChatSetup() tea.Cmd {
+	m.Chat.CheckingSetup = true
+	return CheckChatSetup()
+}
+
+// openChat shows the chat panel, checking the backend the first time.
+func (m *Model) openChat() tea.Cmd {
+	m.ShowChat = true
+	m.Chat.TextArea.Focus()
+	m.renderChatViewport()
+	if m.Chat.Setup == nil && !m.Chat.CheckingSetup {
+		return m.checkChatSetup()
+	}
+	return nil
+}
+
+// chatSetupLine renders the backend status shown at the top of the chat panel.
+func (m *Model) chatSetupLine() string {
+	setup := m.Chat.Setup
+	switch {
+	case m.Chat.CheckingSetup:
+		return ToolStyle.Render("Checking chat setup...")
+	case setup == nil:
+		return ""
+	case setup.Ready && setup.Region != "":
+		return ToolStyle.Render(fmt.Sprintf("● %s in %s · ready", setup.ModelID, setup.Region))
+	case setup.Ready:
+		return ToolStyle.Render(fmt.Sprintf("● %s · ready", setup.ModelID))
+	}
+	problem := setup.Problem
+	if setup.Region != "" {
+		problem += " (" + setup.Region + ")"
+	}
+	return WarningStyle.Render("⚠ "+problem) + "\n" + HelpStyle.Render(setup.Hint+" ("+m.Keys.RetrySetup.Help().Key+": retry)")
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestValidationErrorsAreSetupProblemsOnlyForTheModel(t *testing.T) {
+	tests := []struct {
+		message string
+		setup   bool
+	}{
+		{"The provided model identifier is invalid.", true},
+		{"Invocation of model ID amazon.nova-pro-v1:0 with on-demand throughput isn't supported.", true},
+		{"Input is too long for requested model.", false},
+		{"The stop sequence must not be empty.", false},
+		{"Too many images provided in request.", false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("operation error Bedrock Runtime: Converse: %w",
+			&smithy.GenericAPIError{Code: "ValidationException", Message: tt.message})
+		if got := setupProblem(err); got != tt.setup {
+			t.Errorf("%q: setup problem = %v, want %v", tt.message, got, tt.setup)
+		}
+	}
+}
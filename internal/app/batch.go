@@ -0,0 +1,223 @@
+// File: /loam/internal/app/batch.go
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+)
+
+// BatchResult is one line of batch output, keyed by manifest URL. Error is
+// set instead of Response when the manifest could not be fetched or the
+// model request failed.
+type BatchResult struct {
+	ID       string    `json:"id"`
+	Label    string    `json:"label,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Prompt   string    `json:"prompt,omitempty"`
+	Response string    `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// BatchOptions controls a batch run.
+type BatchOptions struct {
+	// Template is rendered for each manifest, with Input as its .Input.
+	Template PromptTemplate
+	Input    string
+
+	Context     ContextBuilder
+	Concurrency int     // Parallel manifests
+	Rate        float64 // Maximum model requests per second; 0 for no limit
+
+	// Skip lists manifests that already have a result.
+	Skip map[string]bool
+
+	// Progress, if non-nil, is called after each manifest is finished.
+	Progress func(done, total int)
+}
+
+// RunBatch renders the template for every manifest, sends it with the
+// manifest as context and passes each result to emit as it completes.
+// Cancelling ctx stops new manifests from starting; requests already in
+// flight finish and are emitted.
+func RunBatch(ctx context.Context, cs *ChatService, manifests []string, opts BatchOptions, emit func(BatchResult) error) error {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+
+	var pending []string
+	for _, u := range manifests {
+		if !opts.Skip[u] {
+			pending = append(pending, u)
+		}
+	}
+
+	// Every model request, tool rounds included, waits its turn
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		limited := *cs
+		limited.Provider = rateLimitedProvider{ChatProvider: cs.Provider, limit: ticker.C}
+		cs = &limited
+	}
+
+	var (
+		mu      sync.Mutex
+		done    int
+		emitErr error
+		wg      sync.WaitGroup
+	)
+	queue := make(chan string)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				result := cs.batchPrompt(u, opts)
+				result.Time = time.Now().UTC()
+
+				mu.Lock()
+				if emitErr == nil {
+					emitErr = emit(result)
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(pending))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, u := range pending {
+		mu.Lock()
+		failed := emitErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		select {
+		case queue <- u:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if emitErr != nil {
+		return emitErr
+	}
+	return ctx.Err()
+}
+
+// batchPrompt fetches one manifest, renders its prompt and asks the model.
+func (cs *ChatService) batchPrompt(manifestURL string, opts BatchOptions) BatchResult {
+	result := BatchResult{ID: manifestURL, Model: cs.Provider.ModelID()}
+
+	data, err := iiif.FetchDataSync(manifestURL)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to fetch manifest: %v", err)
+		return result
+	}
+	res, err := iiif.ParseResource(data)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	items := iiif.ParseData(data)
+	result.Label = res.Label
+
+	prompt, err := opts.Template.Render(TemplateData{Resource: res, Detail: res, Items: items, Input: opts.Input})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Prompt = prompt
+
+	response, err := cs.SendChatSync(prompt, opts.Context.Build([]*iiif.Resource{res}, items), nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Response = response
+	return result
+}
+
+// rateLimitedProvider waits for a tick of limit before each request.
+type rateLimitedProvider struct {
+	ChatProvider
+	limit <-chan time.Time
+}
+
+func (p rateLimitedProvider) Converse(ctx context.Context, req ChatRequest, onChunk func(string)) (*ChatResponse, error) {
+	select {
+	case <-p.limit:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.ChatProvider.Converse(ctx, req, onChunk)
+}
+
+// OpenBatchOutput opens a JSONL batch output file for appending and returns
+// the manifests that already have a successful result in it, so that an
+// interrupted run can be resumed. The file is first rewritten with one
+// successful line per manifest: failed manifests are dropped, to be retried
+// and written again, as are lines cut short by an interruption.
+func OpenBatchOutput(path string) (*os.File, map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read batch output: %w", err)
+	}
+
+	done := make(map[string]bool)
+	var kept bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var result BatchResult
+		if json.Unmarshal(line, &result) != nil || result.Error != "" || done[result.ID] {
+			continue
+		}
+		done[result.ID] = true
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+
+	if len(data) > 0 && !bytes.Equal(kept.Bytes(), data) {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, kept.Bytes(), 0o644); err != nil {
+			return nil, nil, fmt.Errorf("failed to rewrite batch output: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return nil, nil, fmt.Errorf("failed to rewrite batch output: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open batch output: %w", err)
+	}
+	return f, done, nil
+}
+
+// BatchWriter returns an emit function for RunBatch that writes each result
+// to w as one line of JSON.
+func BatchWriter(w io.Writer) func(BatchResult) error {
+	enc := json.NewEncoder(w)
+	return func(result BatchResult) error {
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to write batch result: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manifestServer serves a manifest labelled with its path at every path
+// except /missing.
+func manifestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"@context": "http://iiif.io/api/presentation/3/context.json", "id": "%s", "type": "Manifest",
+			"label": {"en": ["%s"]}, "items": []}`, r.URL.Path, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// countingProvider counts the requests sent to the provider it wraps.
+type countingProvider struct {
+	ChatProvider
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *countingProvider) Converse(ctx context.Context, req ChatRequest, onChunk func(string)) (*ChatResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	return p.ChatProvider.Converse(ctx, req, onChunk)
+}
+
+func TestRunBatch(t *testing.T) {
+	srv := manifestServer(t)
+	tests := []struct {
+		name      string
+		manifests []string
+		prompt    string
+		skip      map[string]bool
+		want      map[string]string // Response, or "error: " and the error, by manifest
+		wantCalls int
+	}{
+		{
+			name:      "every manifest answered",
+			manifests: []string{"/a", "/b"},
+			prompt:    "describe {{.Resource.Label}}",
+			want:      map[string]string{"/a": "You said: describe a", "/b": "You said: describe b"},
+			wantCalls: 2,
+		},
+		{
+			name:      "fetch failure",
+			manifests: []string{"/a", "/missing"},
+			prompt:    "describe",
+			want:      map[string]string{"/a": "You said: describe", "/missing": "error: failed to fetch manifest"},
+			wantCalls: 1,
+		},
+		{
+			name:      "skipped manifests",
+			manifests: []string{"/a", "/b", "/c"},
+			prompt:    "describe",
+			skip:      map[string]bool{"/b": true},
+			want:      map[string]string{"/a": "You said: describe", "/c": "You said: describe"},
+			wantCalls: 2,
+		},
+		{
+			name:      "tool rounds",
+			manifests: []string{"/a"},
+			prompt:    "/tool echo {}",
+			want:      map[string]string{"/a": "echo {}"},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := mockService(t, 5)
+			counter := &countingProvider{ChatProvider: cs.Provider}
+			cs.Provider = counter
+
+			var urls []string
+			skip := map[string]bool{}
+			for _, m := range tt.manifests {
+				urls = append(urls, srv.URL+m)
+			}
+			for m := range tt.skip {
+				skip[srv.URL+m] = true
+			}
+
+			got := map[string]string{}
+			opts := BatchOptions{Template: PromptTemplate{Name: "p", Text: tt.prompt}, Skip: skip}
+			err := RunBatch(context.Background(), cs, urls, opts, func(r BatchResult) error {
+				id := strings.TrimPrefix(r.ID, srv.URL)
+				if r.Error != "" {
+					got[id] = "error: " + r.Error
+				} else {
+					got[id] = r.Response
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+			for id, want := range tt.want {
+				if !strings.Contains(got[id], want) {
+					t.Errorf("%s: got %q, want %q", id, got[id], want)
+				}
+			}
+			if counter.calls != tt.wantCalls {
+				t.Errorf("%d model requests, want %d", counter.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRunBatchRateLimitsToolRounds(t *testing.T) {
+	srv := manifestServer(t)
+	cs := mockService(t, 5)
+
+	// Two manifests with a tool round each make four requests, the first
+	// of which waits one interval
+	const rate = 20
+	start := time.Now()
+	opts := BatchOptions{Template: PromptTemplate{Name: "p", Text: "/tool echo {}"}, Rate: rate}
+	err := RunBatch(context.Background(), cs, []string{srv.URL + "/a", srv.URL + "/b"}, opts,
+		func(BatchResult) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, min := time.Since(start), 4*time.Second/rate; elapsed < min {
+		t.Errorf("batch took %v, want at least %v at %d requests per second", elapsed, min, rate)
+	}
+}
+
+func TestOpenBatchOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		wantDone []string
+		wantFile string
+	}{
+		{
+			name:     "new file",
+			wantFile: "",
+		},
+		{
+			name:     "answered manifests are kept",
+			existing: `{"id":"a","response":"ok"}` + "\n" + `{"id":"b","response":"ok"}` + "\n",
+			wantDone: []string{"a", "b"},
+			wantFile: `{"id":"a","response":"ok"}` + "\n" + `{"id":"b","response":"ok"}` + "\n",
+		},
+		{
+			name:     "failures are dropped to be retried",
+			existing: `{"id":"a","error":"throttled"}` + "\n" + `{"id":"b","response":"ok"}` + "\n",
+			wantDone: []string{"b"},
+			wantFile: `{"id":"b","response":"ok"}` + "\n",
+		},
+		{
+			name: "retried failure keeps only its answer",
+			existing: `{"id":"a","error":"throttled"}` + "\n" + `{"id":"b","response":"ok"}` + "\n" +
+				`{"id":"a","response":"ok"}` + "\n",
+			wantDone: []string{"a", "b"},
+			wantFile: `{"id":"b","response":"ok"}` + "\n" + `{"id":"a","response":"ok"}` + "\n",
+		},
+		{
+			name:     "line cut short",
+			existing: `{"id":"a","response":"ok"}` + "\n" + `{"id":"b","resp`,
+			wantDone: []string{"a"},
+			wantFile: `{"id":"a","response":"ok"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.jsonl")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f, done, err := OpenBatchOutput(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			var ids []string
+			for id := range done {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if !reflect.DeepEqual(ids, tt.wantDone) {
+				t.Errorf("done = %q, want %q", ids, tt.wantDone)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.wantFile {
+				t.Errorf("file = %q, want %q", data, tt.wantFile)
+			}
+
+			// New results go after the kept ones
+			if err := BatchWriter(f)(BatchResult{ID: "c", Response: "ok"}); err != nil {
+				t.Fatal(err)
+			}
+			data, _ = os.ReadFile(path)
+			if !strings.HasPrefix(string(data), tt.wantFile) || !strings.Contains(string(data), `"id":"c"`) {
+				t.Errorf("file after writing = %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// File: /loam/internal/app/bookmarks.go
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/bookmarks"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bookmarksURL is the URL of the bookmarks pseudo-collection; a folder's URL
+// appends its name.
+const bookmarksURL = "bookmarks:"
+
+// loadBookmarks reads the bookmarks file. It is read again for every change
+// so edits made to it by hand while the TUI runs are kept.
+func loadBookmarks() (*bookmarks.Bookmarks, error) {
+	path, err := bookmarks.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return bookmarks.Load(path)
+}
+
+// editBookmarks applies fn to the bookmarks file and saves it.
+func editBookmarks(fn func(b *bookmarks.Bookmarks) error) error {
+	b, err := loadBookmarks()
+	if err != nil {
+		return err
+	}
+	if err := fn(b); err != nil {
+		return err
+	}
+	return b.Save()
+}
+
+// bookmarkFolder returns the folder new bookmarks go into.
+func (m *Model) bookmarkFolder() string {
+	if m.BookmarkFolder == "" {
+		return bookmarks.DefaultFolder
+	}
+	return m.BookmarkFolder
+}
+
+// addBookmark pins a resource to the current folder.
+func (m *Model) addBookmark(bm bookmarks.Bookmark) {
+	bm.Added = time.Now().UTC()
+	folder := m.bookmarkFolder()
+	added := false
+	err := editBookmarks(func(b *bookmarks.Bookmarks) error {
+		added = b.Add(folder, bm)
+		return nil
+	})
+	switch {
+	case err != nil:
+		m.Status = "Bookmark failed: " + err.Error()
+	case !added:
+		m.Status = fmt.Sprintf("%s is already bookmarked in %s.", bm.Title, folder)
+	default:
+		m.Status = fmt.Sprintf("Bookmarked %s in %s.", bm.Title, folder)
+	}
+}
+
+// bookmarkSelected pins the collection or manifest highlighted in the list.
+func (m *Model) bookmarkSelected() {
+	item, ok := m.List.SelectedItem().(ui.Item)
+	if !ok || item.URL == "Error" {
+		return
+	}
+	if !strings.EqualFold(item.ItemType, "collection") && !strings.EqualFold(item.ItemType, "manifest") {
+		m.Status = "Only collections and manifests can be bookmarked from the list."
+		return
+	}
+	bmType := "Manifest"
+	if strings.EqualFold(item.ItemType, "collection") {
+		bmType = "Collection"
+	}
+	m.addBookmark(bookmarks.Bookmark{Title: item.Title, URL: item.URL, Type: bmType})
+}
+
+// bookmarkDetail pins the manifest in the detail pane, or the highlighted
+// canvas if canvas is set.
+func (m *Model) bookmarkDetail(canvas bool) {
+	bm := bookmarks.Bookmark{Title: m.SelectedItem.Title, URL: m.SelectedItem.URL, Type: "Manifest"}
+	if canvas {
+		res := m.DetailResource
+		if res == nil || len(res.Canvases) == 0 {
+			m.Status = "Wait for the manifest's canvases to load before bookmarking one."
+			return
+		}
+		c := res.Canvases[m.CanvasIndex]
+		bm.Type = "Canvas"
+		bm.Canvas = c.ID
+		bm.Title = fmt.Sprintf("%s — %s", res.Label, c.Label)
+	}
+	m.addBookmark(bm)
+}
+
+// bookmarksLevel reports whether the list shows the bookmarks, and which
+// folder ("" for the list of folders).
+func (m *Model) bookmarksLevel() (string, bool) {
+	if !strings.HasPrefix(m.Level.URL, bookmarksURL) {
+		return "", false
+	}
+	return strings.TrimPrefix(m.Level.URL, bookmarksURL), true
+}
+
+// showBookmarks opens the bookmarks as a pseudo-collection of folders. Esc
+// goes back to the list it was opened from.
+func (m *Model) showBookmarks() {
+	b, err := loadBookmarks()
+	if err != nil {
+		m.Status = "Error: " + err.Error()
+		return
+	}
+	if _, ok := m.bookmarksLevel(); !ok {
+		m.PrevItemsStack = append(m.PrevItemsStack, m.currentLevel())
+	} else {
+		// Already browsing bookmarks: go up to the folders
+		for len(m.PrevItemsStack) > 0 && strings.HasPrefix(m.PrevItemsStack[len(m.PrevItemsStack)-1].URL, bookmarksURL) {
+			m.PrevItemsStack = m.PrevItemsStack[:len(m.PrevItemsStack)-1]
+		}
+	}
+	m.ShowDetail = false
+	m.InList = true
+	m.TextArea.Blur()
+	m.setBookmarksLevel(b, "", 0)
+	m.Status = fmt.Sprintf("%d bookmark folders. Enter opens a folder; new bookmarks go into %s.", len(b.Folders), m.bookmarkFolder())
+}
+
+// openBookmarkFolder descends from the list of folders into one.
+func (m *Model) openBookmarkFolder(name string) {
+	b, err := loadBookmarks()
+	if err != nil {
+		m.Status = "Error: " + err.Error()
+		return
+	}
+	m.PrevItemsStack = append(m.PrevItemsStack, m.currentLevel())
+	m.setBookmarksLevel(b, name, 0)
+	m.Status = fmt.Sprintf("%d bookmarks in %s", len(m.Resource.Items), name)
+}
+
+// setBookmarksLevel shows the folders, or the bookmarks of one folder, as
+// the current level. Its Resource is a collection of the items so that chat
+// sees them like any fetched collection.
+func (m *Model) setBookmarksLevel(b *bookmarks.Bookmarks, folder string, index int) {
+	var items []ui.Item
+	label := "Bookmarks"
+	if folder == "" {
+		for _, f := range b.Folders {
+			items = append(items, ui.Item{
+				Title:    fmt.Sprintf("%s (%d)", f.Name, len(f.Bookmarks)),
+				URL:      bookmarksURL + f.Name,
+				ItemType: "Folder",
+				Order:    len(items),
+			})
+		}
+	} else {
+		label = folder
+		if f := b.Folder(folder); f != nil {
+			for _, bm := range f.Bookmarks {
+				items = append(items, ui.Item{Title: bm.Title, URL: bm.ID(), ItemType: bm.Type, Metadata: m.ItemMetadata[bm.URL],
+					Order: len(items)})
+			}
+		}
+	}
+
+	listItems := make([]list.Item, len(items))
+	for i, item := range items {
+		listItems[i] = item
+	}
+	listItems = m.arrangeItems(bookmarksURL+folder, listItems)
+	m.List.ResetFilter()
+	m.List.SetItems(listItems)
+	if index >= len(listItems) {
+		index = len(listItems) - 1
+	}
+	m.List.Select(index)
+	m.skipHeaders(false)
+
+	m.Level = NavLevel{Label: label, URL: bookmarksURL + folder}
+	m.Resource = &iiif.Resource{ID: m.Level.URL, Type: "Collection", Label: "Bookmarks", Items: items}
+	if folder != "" {
+		m.Resource.Label += ": " + folder
+	}
+	m.refreshChatContext()
+}
+
+// reloadBookmarksLevel shows the bookmarks level again after a change.
+func (m *Model) reloadBookmarksLevel(folder string) {
+	b, err := loadBookmarks()
+	if err != nil {
+		m.Status = "Error: " + err.Error()
+		return
+	}
+	m.setBookmarksLevel(b, folder, m.List.Index())
+}
+
+// openCanvasBookmark opens a bookmarked canvas's manifest in the detail pane
+// at that canvas.
+func (m *Model) openCanvasBookmark(item ui.Item) tea.Cmd {
+	folder, _ := m.bookmarksLevel()
+	b, err := loadBookmarks()
+	if err != nil {
+		m.Status = "Error: " + err.Error()
+		return nil
+	}
+	f := b.Folder(folder)
+	if f == nil {
+		return nil
+	}
+	for _, bm := range f.Bookmarks {
+		if bm.ID() == item.URL {
+			m.SelectedItem = ui.Item{Title: bm.Title, URL: bm.URL, ItemType: "Manifest"}
+			m.ShowDetail = true
+			m.DetailResource = nil
+			m.DetailData = nil
+			m.CanvasIndex = 0
+			m.pendingCanvas = bm.Canvas
+			m.Status = "Viewing detail: " + bm.Title
+			m.Loading = true
+			return tea.Batch(iiif.FetchDetail(bm.URL), m.Spinner.Tick)
+		}
+	}
+	return nil
+}
+
+// selectPendingCanvas highlights the bookmarked canvas once its manifest has
+// loaded.
+func (m *Model) selectPendingCanvas() {
+	if m.pendingCanvas == "" || m.DetailResource == nil {
+		return
+	}
+	for i, c := range m.DetailResource.Canvases {
+		if c.ID == m.pendingCanvas {
+			m.CanvasIndex = i
+		}
+	}
+	m.pendingCanvas = ""
+}
+
+// updateBookmarkKeys handles the keys for managing bookmarks while they are
+// shown in the list. It reports whether it handled the key.
+func (m *Model) updateBookmarkKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	folder, ok := m.bookmarksLevel()
+	if !ok {
+		return false, nil
+	}
+	item, hasItem := m.List.SelectedItem().(ui.Item)
+
+	k := m.Keys
+	switch {
+	case key.Matches(msg, k.DeleteBookmark):
+		if !hasItem {
+			return true, nil
+		}
+		err := editBookmarks(func(b *bookmarks.Bookmarks) error {
+			if folder == "" {
+				name := strings.TrimPrefix(item.URL, bookmarksURL)
+				if f := b.Folder(name); f != nil && len(f.Bookmarks) > 0 {
+					return fmt.Errorf("%s is not empty", name)
+				}
+				b.DeleteFolder(name)
+				return nil
+			}
+			b.Remove(folder, item.URL)
+			return nil
+		})
+		if err != nil {
+			m.Status = "Delete failed: " + err.Error()
+			return true, nil
+		}
+		m.reloadBookmarksLevel(folder)
+		m.Status = "Deleted " + item.Title
+		return true, nil
+
+	case key.Matches(msg, k.BookmarkHere):
+		// Make the highlighted folder, or the one being shown, the workspace
+		// new bookmarks go into
+		name := folder
+		if name == "" && hasItem {
+			name = strings.TrimPrefix(item.URL, bookmarksURL)
+		}
+		if name != "" {
+			m.BookmarkFolder = name
+			m.Status = "New bookmarks go into " + name
+		}
+		return true, nil
+
+	case key.Matches(msg, k.MoveBookmark, k.RenameFolder):
+		move := key.Matches(msg, k.MoveBookmark)
+		if !hasItem || (move && folder == "") || (!move && folder != "") {
+			return true, nil
+		}
+		m.folderAction = "r"
+		if move {
+			m.folderAction = "m"
+		}
+		m.FolderPrompt.Reset()
+		if move {
+			m.FolderPrompt.Placeholder = "Move to folder..."
+		} else {
+			m.FolderPrompt.Placeholder = "Rename folder to..."
+			m.FolderPrompt.SetValue(strings.TrimPrefix(item.URL, bookmarksURL))
+		}
+		m.ShowFolderPrompt = true
+		return true, m.FolderPrompt.Focus()
+	}
+	return false, nil
+}
+
+// updateFolderPrompt handles keys while asking for a folder name.
+func (m *Model) updateFolderPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.ShowFolderPrompt = false
+		m.FolderPrompt.Blur()
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.FolderPrompt.Value())
+		m.ShowFolderPrompt = false
+		m.FolderPrompt.Blur()
+		item, ok := m.List.SelectedItem().(ui.Item)
+		if name == "" || !ok {
+			return m, nil
+		}
+		folder, _ := m.bookmarksLevel()
+		err := editBookmarks(func(b *bookmarks.Bookmarks) error {
+			if m.folderAction == "m" {
+				return b.Move(folder, item.URL, name)
+			}
+			return b.Rename(strings.TrimPrefix(item.URL, bookmarksURL), name)
+		})
+		if err != nil {
+			m.Status = "Error: " + err.Error()
+			return m, nil
+		}
+		if m.folderAction == "r" && strings.EqualFold(m.BookmarkFolder, strings.TrimPrefix(item.URL, bookmarksURL)) {
+			m.BookmarkFolder = name
+		}
+		m.reloadBookmarksLevel(folder)
+		if m.folderAction == "m" {
+			m.Status = fmt.Sprintf("Moved %s to %s.", item.Title, name)
+		} else {
+			m.Status = "Renamed folder to " + name
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.FolderPrompt, cmd = m.FolderPrompt.Update(msg)
+	return m, cmd
+}
@@ -6,51 +6,115 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	loamconfig "github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/usage"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// InferenceConfig represents the configuration for the inference.
+// InferenceConfig represents the configuration for the inference. Nil
+// parameters are left to the model's defaults.
 type InferenceConfig struct {
-	MaxNewTokens int `json:"max_new_tokens"`
+	MaxNewTokens  int      `json:"max_new_tokens"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
 }
 
-// TextContent represents the structure of each content object.
+// TextContent represents a plain text block, as used in the system prompt.
 type TextContent struct {
 	Text string `json:"text"`
 }
 
+// ContentBlock is one block of a chat message: text, an image, a tool
+// invocation requested by the model, or the result of running a tool.
+type ContentBlock struct {
+	Text       string        `json:"text,omitempty"`
+	Image      *ImageContent `json:"image,omitempty"`
+	ToolUse    *ToolUse      `json:"toolUse,omitempty"`
+	ToolResult *ToolResult   `json:"toolResult,omitempty"`
+}
+
+// ToolUse is a request from the model to run a tool.
+type ToolUse struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+// ToolResult carries the output of a tool back to the model.
+type ToolResult struct {
+	ToolUseID string        `json:"toolUseId"`
+	Content   []TextContent `json:"content"`
+	Status    string        `json:"status,omitempty"`
+}
+
 // Message represents a single message in the chat.
 type Message struct {
-	Role    string        `json:"role"`
-	Content []TextContent `json:"content"`
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// Text returns the concatenated text blocks of the message.
+func (m Message) Text() string {
+	var parts []string
+	for _, c := range m.Content {
+		if c.Text != "" {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ToolUses returns the tool invocations requested in the message.
+func (m Message) ToolUses() []ToolUse {
+	var uses []ToolUse
+	for _, c := range m.Content {
+		if c.ToolUse != nil {
+			uses = append(uses, *c.ToolUse)
+		}
+	}
+	return uses
 }
 
-// ChatRequest represents the request payload for AWS Bedrock's chat model.
+// ChatRequest represents the request payload for the chat model.
 type ChatRequest struct {
+	System          []TextContent   `json:"system,omitempty"`
 	InferenceConfig InferenceConfig `json:"inferenceConfig"`
 	Messages        []Message       `json:"messages"`
+	ToolConfig      *ToolConfig     `json:"toolConfig,omitempty"`
 }
 
-// ChatResponse represents the response from AWS Bedrock's chat model.
+// ChatResponse represents the response from the chat model.
 type ChatResponse struct {
 	Output struct {
-		Message struct {
-			Content []struct {
-				Text string `json:"text"`
-			} `json:"content"`
-			Role string `json:"role"`
-		} `json:"message"`
+		Message Message `json:"message"`
 	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      *Usage `json:"usage,omitempty"`
+
+	// Latency is the time the request took, measured by ChatService.Converse.
+	Latency time.Duration `json:"-"`
 }
 
 // ChatResponseMsg represents a successful response from the chat model.
 type ChatResponseMsg struct {
-	Response string
+	Message    Message
+	StopReason string
+	Usage      Usage
+	Latency    time.Duration
+}
+
+// ToolResultsMsg carries the results of running the tools the model asked for.
+type ToolResultsMsg struct {
+	Message Message
 }
 
 // ChatErrorMsg represents an error that occurred during chat invocation.
@@ -63,110 +127,239 @@ type FoundationModelsMsg struct {
 	Models []string
 }
 
-// ChatService encapsulates the AWS Bedrock Runtime and Bedrock clients.
+// ChatChunkMsg carries a piece of a reply that is still being generated.
+// Reading the next message from stream continues the reply.
+type ChatChunkMsg struct {
+	Text   string
+	stream <-chan tea.Msg
+}
+
+// ChatService sends conversations to a chat provider and runs the tools the
+// model asks for.
 type ChatService struct {
-	BedrockClient      *bedrockruntime.Client
-	BedrockModelClient *bedrock.Client
-}
-
-// NewChatService initializes the ChatService with the Bedrock Runtime and Bedrock clients.
-// It accepts an optional profile name. If profileName is empty, the default profile is used.
-func NewChatService(profileName string) (*ChatService, error) {
-	var cfg aws.Config
-	var err error
-
-	if profileName != "" {
-		cfg, err = config.LoadDefaultConfig(context.Background(),
-			config.WithSharedConfigProfile(profileName),
-			config.WithRegion("us-east-1"),
-		)
-	} else {
-		cfg, err = config.LoadDefaultConfig(context.Background(),
-			config.WithRegion("us-east-1"),
-		)
-	}
+	Provider ChatProvider
+
+	// SystemPrompt replaces DefaultSystemPrompt when set. The chat context is
+	// appended to it on every request.
+	SystemPrompt string
+
+	// Tools the model may call, and the maximum number of tool rounds per
+	// prompt. A nil Toolbox disables tool use.
+	Tools    *Toolbox
+	MaxSteps int
+
+	// Inference holds the sampling parameters sent with each request.
+	Inference InferenceConfig
+
+	// UsageLog records the token counts of every request, if non-nil.
+	UsageLog *usage.Log
+}
+
+// NewChatService initializes a ChatService backed by AWS Bedrock, using
+// modelID or the default model. Settings left empty in bcfg come from the
+// AWS SDK's environment variables and shared config; the region falls back
+// to us-east-1.
+func NewChatService(bcfg loamconfig.BedrockConfig, modelID string) (*ChatService, error) {
+	cfg, err := loadAWSConfig(bcfg)
 	if err != nil {
 		return nil, err
 	}
 
-	runtimeClient := bedrockruntime.NewFromConfig(cfg)
-	modelClient := bedrock.NewFromConfig(cfg)
-
+	if modelID == "" {
+		modelID = defaultModelID
+	}
 	return &ChatService{
-		BedrockClient:      runtimeClient,
-		BedrockModelClient: modelClient,
+		Provider: &BedrockProvider{
+			Client: newRuntimeClient(cfg, bcfg),
+			ModelClient: bedrock.NewFromConfig(cfg, func(o *bedrock.Options) {
+				if bcfg.ControlEndpointURL != "" {
+					o.BaseEndpoint = aws.String(bcfg.ControlEndpointURL)
+				}
+			}),
+			Model:  modelID,
+			Region: cfg.Region,
+		},
 	}, nil
 }
 
-// SendChatCommand creates a Bubble Tea command that sends the prompt and context to Bedrock's Claude and returns the response.
-func (cs *ChatService) SendChatCommand(prompt string, chatContext string) tea.Cmd {
-	return func() tea.Msg {
-		modelID := "amazon.nova-lite-v1:0"
+// NewBedrockClient creates a Bedrock Runtime client configured like the chat
+// service's, for calls such as embeddings that don't depend on the chat
+// provider.
+func NewBedrockClient(bcfg loamconfig.BedrockConfig) (*bedrockruntime.Client, error) {
+	cfg, err := loadAWSConfig(bcfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRuntimeClient(cfg, bcfg), nil
+}
 
-		combinedText := fmt.Sprintf("%s\n\n%s", chatContext, prompt)
+// loadAWSConfig loads the AWS configuration, with the profile and region
+// from bcfg if they are set.
+func loadAWSConfig(bcfg loamconfig.BedrockConfig) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{}
+	if bcfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(bcfg.Profile))
+	}
+	if bcfg.Region != "" {
+		opts = append(opts, config.WithRegion(bcfg.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	return cfg, nil
+}
 
-		userMessage := Message{
-			Role: "user",
-			Content: []TextContent{
-				{Text: combinedText},
-			},
+func newRuntimeClient(cfg aws.Config, bcfg loamconfig.BedrockConfig) *bedrockruntime.Client {
+	return bedrockruntime.NewFromConfig(cfg, func(o *bedrockruntime.Options) {
+		if bcfg.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(bcfg.EndpointURL)
 		}
+	})
+}
 
-		requestPayload := ChatRequest{
-			InferenceConfig: InferenceConfig{
-				MaxNewTokens: 1000,
-			},
-			Messages: []Message{userMessage},
+// NewChatServiceFromConfig creates the chat service selected by the
+// configuration, with its system prompt and tools.
+func NewChatServiceFromConfig(cfg loamconfig.Config) (*ChatService, error) {
+	var (
+		cs  *ChatService
+		err error
+	)
+	switch cfg.Chat.Provider {
+	case ProviderBedrock, "":
+		if cfg.Chat.Model != "" {
+			if err := CheckBedrockModel(cfg.Chat.Model); err != nil {
+				return nil, err
+			}
 		}
-
-		body, err := json.Marshal(requestPayload)
+		cs, err = NewChatService(cfg.Bedrock, cfg.Chat.Model)
 		if err != nil {
-			return ChatErrorMsg{Error: err}
+			return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
 		}
-
-		output, err := cs.BedrockClient.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-			ModelId:     aws.String(modelID),
-			ContentType: aws.String("application/json"),
-			Body:        body,
-		})
+	case ProviderMock:
+		provider, err := NewMockProvider(cfg.Chat.MockScript)
 		if err != nil {
-			return ChatErrorMsg{Error: err}
+			return nil, err
 		}
+		cs = &ChatService{Provider: provider}
+	default:
+		return nil, fmt.Errorf("unknown chat provider %q", cfg.Chat.Provider)
+	}
 
-		var response ChatResponse
-		if err := json.Unmarshal(output.Body, &response); err != nil {
-			return ChatErrorMsg{Error: err}
-		}
+	cs.SystemPrompt = cfg.Chat.SystemPrompt
+	cs.Inference = InferenceFromConfig(cfg.Chat.Inference)
+	if err := cs.Inference.Validate(CapabilitiesFor(cs.Provider.ModelID())); err != nil {
+		return nil, fmt.Errorf("invalid inference settings for %s: %w", cs.Provider.ModelID(), err)
+	}
+	cs.UsageLog = usageLog(cfg)
+	// Tool use is disabled entirely when no tool steps are allowed
+	if cfg.Chat.MaxToolSteps > 0 {
+		cs.Tools = NewToolbox()
+		cs.MaxSteps = cfg.Chat.MaxToolSteps
+	}
+	return cs, nil
+}
 
-		if len(response.Output.Message.Content) > 0 {
-			assistantMessage := response.Output.Message.Content[0].Text
-			return ChatResponseMsg{Response: assistantMessage}
-		}
+// defaultMaxTokens limits replies when no maximum is configured.
+const defaultMaxTokens = 1000
+
+// defaultRegion is used when neither the configuration nor the AWS
+// environment sets a region.
+const defaultRegion = "us-east-1"
 
-		return ChatErrorMsg{Error: fmt.Errorf("no assistant message found in the response")}
+// defaultModelID is the Bedrock model used for chat.
+const defaultModelID = "amazon.nova-lite-v1:0"
+
+// DefaultSystemPrompt steers the model when no system prompt is configured.
+const DefaultSystemPrompt = "You are helping a user explore IIIF collections and manifests. " +
+	"Use the context about the resources they are browsing. " +
+	"Use the available tools to fetch resources that are not in the context."
+
+// Converse sends the conversation so far, with chatContext as the system
+// prompt, and returns the model's next message. If onChunk is non-nil the
+// reply text is also passed to it as it streams in. The response's Usage is
+// always set, estimated if the provider did not report it, and recorded in
+// the usage log.
+func (cs *ChatService) Converse(ctx context.Context, history []Message, chatContext string, onChunk func(string)) (*ChatResponse, error) {
+	requestPayload := ChatRequest{
+		InferenceConfig: cs.Inference,
+		Messages:        history,
+	}
+	if requestPayload.InferenceConfig.MaxNewTokens == 0 {
+		requestPayload.InferenceConfig.MaxNewTokens = defaultMaxTokens
+	}
+	systemPrompt := cs.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = DefaultSystemPrompt
+	}
+	if chatContext != "" {
+		systemPrompt += "\n\nContext:\n" + chatContext
+	}
+	requestPayload.System = []TextContent{{Text: systemPrompt}}
+	if cs.Tools != nil {
+		requestPayload.ToolConfig = cs.Tools.Config()
+	}
+
+	start := time.Now()
+	response, err := cs.Provider.Converse(ctx, requestPayload, onChunk)
+	if err != nil {
+		return nil, err
+	}
+	response.Latency = time.Since(start)
+	if response.Usage == nil {
+		response.Usage = estimateUsage(requestPayload, response.Output.Message)
+	}
+	cs.recordUsage(response)
+	return response, nil
+}
+
+// SendChatCommand creates a Bubble Tea command that sends the conversation to
+// the model. The reply streams back as ChatChunkMsgs followed by a
+// ChatResponseMsg, or a ChatErrorMsg if the request fails.
+func (cs *ChatService) SendChatCommand(history []Message, chatContext string) tea.Cmd {
+	return func() tea.Msg {
+		stream := make(chan tea.Msg)
+		go func() {
+			response, err := cs.Converse(context.Background(), history, chatContext, func(text string) {
+				stream <- ChatChunkMsg{Text: text, stream: stream}
+			})
+			if err != nil {
+				stream <- ChatErrorMsg{Error: err}
+				return
+			}
+			stream <- ChatResponseMsg{
+				Message:    response.Output.Message,
+				StopReason: response.StopReason,
+				Usage:      *response.Usage,
+				Latency:    response.Latency,
+			}
+		}()
+		return <-stream
+	}
+}
+
+// Next returns a command that waits for the rest of the reply.
+func (msg ChatChunkMsg) Next() tea.Cmd {
+	return func() tea.Msg {
+		return <-msg.stream
 	}
 }
 
 // GetFoundationModels fetches the list of available foundation models.
 func (cs *ChatService) GetFoundationModels() tea.Cmd {
 	return func() tea.Msg {
-		ctx := context.Background()
-		input := &bedrock.ListFoundationModelsInput{}
-
-		output, err := cs.BedrockModelClient.ListFoundationModels(ctx, input)
+		modelIDs, err := cs.Provider.Models(context.Background())
 		if err != nil {
 			return ChatErrorMsg{Error: err}
 		}
 
-		if len(output.ModelSummaries) == 0 {
+		if len(modelIDs) == 0 {
 			return FoundationModelsMsg{Models: []string{"No foundation models available."}}
 		}
 
-		modelIDs := make([]string, len(output.ModelSummaries))
-		for i, model := range output.ModelSummaries {
-			modelIDs[i] = *model.ModelId
-		}
-
 		return FoundationModelsMsg{Models: modelIDs}
 	}
 }
@@ -175,78 +368,84 @@ func (cs *ChatService) GetFoundationModels() tea.Cmd {
 func ProcessError(err error, modelID string) {
 }
 
-var (
-	chatServiceInstance *ChatService
-	chatServiceErr      error
-)
-
-func init() {
-	chatServiceInstance, chatServiceErr = NewChatService("")
-}
-
-// SendChat sends a chat message with context and returns a command to handle it.
-func SendChat(prompt string, chatContext string) tea.Cmd {
-	if chatServiceInstance == nil {
-		return func() tea.Msg {
-			return ChatErrorMsg{Error: chatServiceErr}
+// SendChat sends the conversation with context and returns a command to
+// handle the reply. inference replaces the configured sampling parameters.
+func SendChat(history []Message, chatContext string, inference InferenceConfig) tea.Cmd {
+	return func() tea.Msg {
+		cs, err := chatService()
+		if err != nil {
+			return ChatErrorMsg{Error: err}
 		}
+		withSettings := *cs
+		withSettings.Inference = inference
+		return withSettings.SendChatCommand(history, chatContext)()
 	}
-	return chatServiceInstance.SendChatCommand(prompt, chatContext)
 }
 
-// GetModels sends a command to fetch foundation models.
-func GetModels() tea.Cmd {
-	if chatServiceInstance == nil {
-		return func() tea.Msg {
-			return ChatErrorMsg{Error: chatServiceErr}
+// RunTools executes the tools requested by the model and returns their results.
+func RunTools(uses []ToolUse) tea.Cmd {
+	return func() tea.Msg {
+		cs, err := chatService()
+		if err != nil {
+			return ChatErrorMsg{Error: err}
+		}
+		if cs.Tools == nil {
+			return ChatErrorMsg{Error: fmt.Errorf("tool use is not available")}
 		}
+		return cs.Tools.RunCommand(uses)()
 	}
-	return chatServiceInstance.GetFoundationModels()
 }
 
-// SendChatSync sends a prompt with context to the chat model synchronously
-func (cs *ChatService) SendChatSync(prompt string, chatContext string) (string, error) {
-	modelID := "amazon.nova-lite-v1:0" // Ensure this is the correct model ID
-
-	combinedText := fmt.Sprintf("%s\n\n%s", chatContext, prompt)
-
-	userMessage := Message{
-		Role: "user",
-		Content: []TextContent{
-			{Text: combinedText},
-		},
-	}
-
-	requestPayload := ChatRequest{
-		InferenceConfig: InferenceConfig{
-			MaxNewTokens: 1000,
-		},
-		Messages: []Message{userMessage},
-	}
-
-	body, err := json.Marshal(requestPayload)
+// ChatCapabilities returns the capabilities of the configured chat model.
+func ChatCapabilities() (string, ModelCapabilities) {
+	cs, err := chatService()
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request payload: %w", err)
+		return "", ModelCapabilities{}
 	}
+	modelID := cs.Provider.ModelID()
+	return modelID, CapabilitiesFor(modelID)
+}
 
-	output, err := cs.BedrockClient.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-		Body:        body,
-	})
+// SendChatSync sends a prompt with context to the chat model synchronously,
+// running any requested tools until the model answers or MaxSteps is reached.
+// onTool, if non-nil, is called for each tool invocation and its result.
+func (cs *ChatService) SendChatSync(prompt string, chatContext string, onTool func(use ToolUse, result string)) (string, error) {
+	history := []Message{{
+		Role:    "user",
+		Content: []ContentBlock{{Text: prompt}},
+	}}
+	history, err := cs.converseSync(context.Background(), history, chatContext, onTool)
 	if err != nil {
-		return "", fmt.Errorf("failed to invoke model: %w", err)
+		return "", err
 	}
+	return history[len(history)-1].Text(), nil
+}
 
-	var response ChatResponse
-	if err := json.Unmarshal(output.Body, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+// converseSync continues the conversation, running tools, until the model
+// answers. It returns the history with the answer as its last message.
+func (cs *ChatService) converseSync(ctx context.Context, history []Message, chatContext string, onTool func(use ToolUse, result string)) ([]Message, error) {
+	for step := 0; ; step++ {
+		response, err := cs.Converse(ctx, history, chatContext, nil)
+		if err != nil {
+			return nil, err
+		}
+		reply := response.Output.Message
+		history = append(history, reply)
 
-	if len(response.Output.Message.Content) > 0 {
-		assistantMessage := response.Output.Message.Content[0].Text
-		return assistantMessage, nil
-	}
+		uses := reply.ToolUses()
+		if response.StopReason != "tool_use" || len(uses) == 0 || cs.Tools == nil {
+			return history, nil
+		}
+		if step >= cs.MaxSteps {
+			return nil, fmt.Errorf("model was still calling tools after %d steps", cs.MaxSteps)
+		}
 
-	return "", fmt.Errorf("no assistant message found in the response")
+		results := cs.Tools.Execute(ctx, uses)
+		if onTool != nil {
+			for i, use := range uses {
+				onTool(use, results.Content[i].ToolResult.Content[0].Text)
+			}
+		}
+		history = append(history, results)
+	}
 }
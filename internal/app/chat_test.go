@@ -0,0 +1,255 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// echoToolbox offers one tool that returns its input.
+func echoToolbox() *Toolbox {
+	tb := &Toolbox{}
+	tb.Tools = []ChatTool{newTool("echo", "Return the input.", map[string]interface{}{},
+		nil, func(ctx context.Context, input json.RawMessage) (string, error) {
+			return "echo " + string(input), nil
+		})}
+	return tb
+}
+
+func mockService(t *testing.T, maxSteps int) *ChatService {
+	t.Helper()
+	p, err := NewMockProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ChunkDelay = 0
+	return &ChatService{Provider: p, Tools: echoToolbox(), MaxSteps: maxSteps}
+}
+
+func TestSendChatSyncRunsTools(t *testing.T) {
+	cs := mockService(t, 1)
+	var calls []string
+	reply, err := cs.SendChatSync(`/tool echo {"x": 1}`, "", func(use ToolUse, result string) {
+		calls = append(calls, use.Name+": "+result)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != `echo: echo {"x": 1}` {
+		t.Errorf("tool calls = %q", calls)
+	}
+	if !strings.Contains(reply, `echo {"x": 1}`) {
+		t.Errorf("reply = %q, want the tool result", reply)
+	}
+}
+
+func TestConverseSyncStopsAtMaxSteps(t *testing.T) {
+	cs := mockService(t, 0)
+	_, err := cs.converseSync(context.Background(), []Message{userMessage("/tool echo {}")}, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "after 0 steps") {
+		t.Errorf("error = %v, want the step limit", err)
+	}
+}
+
+// chatModel returns a model chatting with the mock provider, scripted with
+// rules, with its config directory in a temporary directory.
+func chatModel(t *testing.T, maxSteps int, rules ...MockRule) *Model {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	cfg := config.Default()
+	cfg.Chat.Provider = ProviderMock
+	cfg.Chat.MockScript = writeMockScript(t, MockScript{Rules: rules})
+	cfg.Chat.MaxToolSteps = maxSteps
+	cfg.Usage.Disabled = true
+	ConfigureChat(cfg)
+	t.Cleanup(func() { ConfigureChat(config.Default()) })
+
+	m := InitialModel(cfg)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m.ShowChat = true
+	return m
+}
+
+// send sends a prompt through the update loop and runs the commands it
+// returns until the turn is over, returning the messages they produced.
+func send(t *testing.T, m *Model, prompt string) []tea.Msg {
+	t.Helper()
+	var msgs []tea.Msg
+	cmds := []tea.Cmd{m.sendPrompt(prompt, prompt)}
+	for len(cmds) > 0 {
+		if len(msgs) > 100 {
+			t.Fatal("the turn never ended")
+		}
+		cmd := cmds[0]
+		cmds = cmds[1:]
+		if cmd == nil {
+			continue
+		}
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			cmds = append(cmds, batch...)
+			continue
+		}
+		switch msg.(type) {
+		case ChatChunkMsg, ChatResponseMsg, ToolResultsMsg, ChatErrorMsg, ChatRetrievalMsg:
+		default:
+			// Spinner ticks, previews and the like are not part of the turn
+			continue
+		}
+		msgs = append(msgs, msg)
+		_, next := m.Update(msg)
+		cmds = append(cmds, next)
+	}
+	return msgs
+}
+
+// roles lists the roles of a conversation's messages.
+func roles(history []Message) string {
+	var r []string
+	for _, msg := range history {
+		r = append(r, msg.Role)
+	}
+	return strings.Join(r, " ")
+}
+
+func TestUpdateChatRoundTrip(t *testing.T) {
+	m := chatModel(t, 5)
+
+	msgs := send(t, m, "hello there")
+	var chunks []string
+	for _, msg := range msgs[:len(msgs)-1] {
+		chunk, ok := msg.(ChatChunkMsg)
+		if !ok {
+			t.Fatalf("got %T before the reply, want chunks", msg)
+		}
+		chunks = append(chunks, chunk.Text)
+	}
+	if got := strings.Join(chunks, ""); got != "You said: hello there" {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if _, ok := msgs[len(msgs)-1].(ChatResponseMsg); !ok {
+		t.Errorf("last message is %T, want ChatResponseMsg", msgs[len(msgs)-1])
+	}
+
+	if got := roles(m.Chat.History); got != "user assistant" {
+		t.Errorf("history = %s", got)
+	}
+	if m.Chat.Streaming != "" || m.Chat.InFlight {
+		t.Errorf("turn still in progress: streaming %q, in flight %v", m.Chat.Streaming, m.Chat.InFlight)
+	}
+	if m.Chat.Session == nil || len(m.Chat.Session.History) != 2 {
+		t.Error("session was not saved with the turn")
+	}
+}
+
+func TestUpdateChatToolRound(t *testing.T) {
+	m := chatModel(t, 5)
+	send(t, m, "/tool no_such_tool {}")
+	if got := roles(m.Chat.History); got != "user assistant user assistant" {
+		t.Errorf("history = %s", got)
+	}
+	if m.Chat.Steps != 1 {
+		t.Errorf("steps = %d, want 1", m.Chat.Steps)
+	}
+}
+
+func TestUpdateChatRollsBackAtMaxSteps(t *testing.T) {
+	m := chatModel(t, 1)
+	send(t, m, "hello")
+	m.MaxToolSteps = 0
+	send(t, m, "/tool no_such_tool {}")
+
+	// The failed turn is dropped whole, so the history still ends on a reply
+	if got := roles(m.Chat.History); got != "user assistant" {
+		t.Errorf("history = %s, want the first turn only", got)
+	}
+	if m.Chat.InFlight {
+		t.Error("still in flight after the step limit")
+	}
+}
+
+func TestUpdateChatRollsBackErrors(t *testing.T) {
+	m := chatModel(t, 5)
+	send(t, m, "hello")
+	msgs := send(t, m, "/error throttled")
+	if _, ok := msgs[len(msgs)-1].(ChatErrorMsg); !ok {
+		t.Fatalf("last message is %T, want ChatErrorMsg", msgs[len(msgs)-1])
+	}
+	if got := roles(m.Chat.History); got != "user assistant" {
+		t.Errorf("history = %s, want the first turn only", got)
+	}
+}
+
+func TestCitationsResolveAgainstSentContext(t *testing.T) {
+	m := chatModel(t, 5, MockRule{Match: "^cite$", Reply: "See [i1]."})
+	m.Chat.References = map[string]Reference{"i1": {ID: "i1", URL: "https://example.org/a", Canvas: -1}}
+	cmd := m.sendPrompt("cite", "cite")
+
+	// The list changes while the reply is on its way
+	m.Chat.References = map[string]Reference{"i1": {ID: "i1", URL: "https://example.org/b", Canvas: -1}}
+	for cmd != nil {
+		msg := cmd()
+		_, cmd = m.Update(msg)
+		if _, ok := msg.(ChatResponseMsg); ok {
+			break
+		}
+	}
+
+	if len(m.Chat.Citations) != 1 || m.Chat.Citations[0].URL != "https://example.org/a" {
+		t.Errorf("citations = %+v, want the item listed when the prompt was sent", m.Chat.Citations)
+	}
+}
+
+func TestBusyWhileReplyInFlight(t *testing.T) {
+	m := chatModel(t, 5)
+	cmd := m.sendPrompt("hello", "hello")
+	if !m.busy() {
+		t.Error("not busy while the reply is on its way")
+	}
+	for cmd != nil {
+		msg := cmd()
+		_, cmd = m.Update(msg)
+		if _, ok := msg.(ChatResponseMsg); ok {
+			break
+		}
+	}
+	if m.busy() {
+		t.Error("still busy after the reply")
+	}
+}
+
+func TestSendRefusedWhileReplyInFlight(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(m *Model)
+	}{
+		{"typed message", func(m *Model) {
+			m.Chat.TextArea.SetValue("again")
+			m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}},
+		{"template", func(m *Model) {
+			m.openTemplatePalette()
+			m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := chatModel(t, 5)
+			m.sendPrompt("hello", "hello")
+			tt.send(m)
+			if got := roles(m.Chat.History); got != "user" {
+				t.Errorf("history = %s, want the pending turn only", got)
+			}
+			if !strings.HasPrefix(m.Status, "Wait for the reply") {
+				t.Errorf("status = %q", m.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// File: /loam/internal/app/citations.go
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/index"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// citationInstructions heads the chat context so that answers can be traced
+// back to the resources they are about.
+const citationInstructions = "When you mention a resource, canvas or item below, cite it with its reference id in square brackets, e.g. [i3] or [r2.c1]."
+
+// Reference is a resource, canvas, list item or retrieved manifest that the
+// model can cite by its reference id.
+type Reference struct {
+	ID    string // Reference id, e.g. "i3" or "r2.c1"
+	Label string
+	Type  string
+	URL   string // The resource, or for canvases the manifest containing it
+
+	// Canvas is the position of a cited canvas in its manifest, or -1.
+	Canvas int
+}
+
+func resourceRef(depth int) string       { return fmt.Sprintf("r%d", depth+1) }
+func canvasRef(depth, canvas int) string { return fmt.Sprintf("r%d.c%d", depth+1, canvas+1) }
+func itemRef(i int) string               { return fmt.Sprintf("i%d", i+1) }
+func hitRef(i int) string                { return fmt.Sprintf("h%d", i+1) }
+
+// citationPattern matches a reference id cited in square brackets.
+var citationPattern = regexp.MustCompile(`\[((?:r\d+(?:\.c\d+)?|i\d+|h\d+)(?:\s*,\s*(?:r\d+(?:\.c\d+)?|i\d+|h\d+))*)\]`)
+
+// References returns what each reference id in the context built from the
+// same arguments by BuildWithHits refers to.
+func (b ContextBuilder) References(resources []*iiif.Resource, items []ui.Item, hits []index.Hit) map[string]Reference {
+	refs := make(map[string]Reference)
+	for depth, res := range resources {
+		if res == nil {
+			continue
+		}
+		id := resourceRef(depth)
+		refs[id] = Reference{ID: id, Label: res.Label, Type: res.Type, URL: res.ID, Canvas: -1}
+		for i, c := range res.Canvases {
+			id := canvasRef(depth, i)
+			refs[id] = Reference{ID: id, Label: c.Label, Type: "Canvas", URL: res.ID, Canvas: i}
+		}
+	}
+	for i, item := range items {
+		id := itemRef(i)
+		refs[id] = Reference{ID: id, Label: item.Title, Type: item.ItemType, URL: item.URL, Canvas: -1}
+	}
+	for i, hit := range hits {
+		id := hitRef(i)
+		refs[id] = Reference{ID: id, Label: hit.Label, Type: hit.Type, URL: hit.ID, Canvas: -1}
+	}
+	return refs
+}
+
+// ParseCitations returns the references cited in text, in order of first
+// appearance. Ids missing from refs are ignored.
+func ParseCitations(text string, refs map[string]Reference) []Reference {
+	var cited []Reference
+	seen := make(map[string]bool)
+	for _, m := range citationPattern.FindAllStringSubmatch(text, -1) {
+		for _, id := range strings.Split(m[1], ",") {
+			id = strings.TrimSpace(id)
+			ref, ok := refs[id]
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			cited = append(cited, ref)
+		}
+	}
+	return cited
+}
+
+// renderCitations styles the citations in text, highlighting the selected
+// reference id.
+func renderCitations(text, selected string) string {
+	return citationPattern.ReplaceAllStringFunc(text, func(match string) string {
+		ids := strings.Split(strings.Trim(match, "[]"), ",")
+		for i, id := range ids {
+			id = strings.TrimSpace(id)
+			if id == selected {
+				ids[i] = SelectedCitationStyle.Render(id)
+			} else {
+				ids[i] = CitationStyle.Render(id)
+			}
+		}
+		return CitationStyle.Render("[") + strings.Join(ids, CitationStyle.Render(", ")) + CitationStyle.Render("]")
+	})
+}
+
+// assistantLine renders a reply for the chat viewport.
+func assistantLine(text, selected string) string {
+	return AssistantStyle.Render("Assistant: ") + renderCitations(strings.TrimSpace(text), selected)
+}
+
+// sendChat sends the conversation with the current context, remembering the
+// references it was sent with for citations in the reply.
+func (m *Model) sendChat() tea.Cmd {
+	m.Chat.sentReferences = m.Chat.References
+	return SendChat(m.Chat.History, m.Chat.Context, m.Chat.Inference)
+}
+
+// setCitations records the citations in a reply that was just appended to
+// the chat messages, so they can be selected and followed.
+func (m *Model) setCitations(reply string) {
+	m.Chat.Citations = ParseCitations(reply, m.Chat.sentReferences)
+	m.Chat.Cited = -1
+	m.Chat.citedReply = reply
+	m.Chat.citedMessage = len(m.Chat.Messages) - 1
+}
+
+// cycleCitation selects the next citation in the latest reply.
+func (m *Model) cycleCitation() {
+	if len(m.Chat.Citations) == 0 {
+		m.Status = "The latest reply has no citations."
+		return
+	}
+	m.selectCitation((m.Chat.Cited + 1) % len(m.Chat.Citations))
+}
+
+// selectCitation highlights citation i of the latest reply.
+func (m *Model) selectCitation(i int) {
+	m.Chat.Cited = i
+	ref := m.Chat.Citations[i]
+	if n := m.Chat.citedMessage; n >= 0 && n < len(m.Chat.Messages) {
+		m.Chat.Messages[n] = assistantLine(m.Chat.citedReply, ref.ID)
+		m.renderChatViewport()
+	}
+	m.Status = fmt.Sprintf("[%s] %s: %s — enter to go to it", ref.ID, ref.Type, ref.Label)
+}
+
+// followCitation shows the selected citation: in the detail pane if it is a
+// canvas of the open manifest, in the results list if it is listed there, or
+// otherwise in the browser.
+func (m *Model) followCitation() {
+	ref := m.Chat.Citations[m.Chat.Cited]
+
+	if res := m.DetailResource; m.ShowDetail && res != nil && res.ID == ref.URL {
+		if ref.Canvas >= 0 && ref.Canvas < len(res.Canvases) {
+			m.CanvasIndex = ref.Canvas
+		}
+		m.ShowChat = false
+		m.Status = fmt.Sprintf("Viewing detail: %s", res.Label)
+		return
+	}
+
+	if i, ok := m.listIndex(ref); ok {
+		item := m.List.VisibleItems()[i].(ui.Item)
+		m.List.Select(i)
+		m.ShowChat = false
+		if m.ShowDetail {
+			m.ShowDetail = false
+			m.DetailResource = nil
+			m.DetailData = nil
+			m.refreshChatContext()
+		}
+		m.InList = true
+		m.TextArea.Blur()
+		m.Status = fmt.Sprintf("Selected %s", item.Title)
+		return
+	}
+
+	if err := iiif.OpenURL(ref.URL); err != nil {
+		m.Status = "Failed to open URL"
+	} else {
+		m.Status = "Opened in browser"
+	}
+}
+
+// listIndex finds a cited collection or manifest among the visible items of
+// the results list, clearing the filter if it hides it.
+func (m *Model) listIndex(ref Reference) (int, bool) {
+	if ref.Canvas >= 0 {
+		return 0, false
+	}
+	find := func() (int, bool) {
+		for i, li := range m.List.VisibleItems() {
+			if item, ok := li.(ui.Item); ok && item.URL == ref.URL {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	if i, ok := find(); ok || m.List.FilterState() == list.Unfiltered {
+		return i, ok
+	}
+	m.List.ResetFilter()
+	return find()
+}
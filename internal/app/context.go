@@ -0,0 +1,267 @@
+// File: /loam/internal/app/context.go
+
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/index"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+)
+
+// Context fields that can be selected for inclusion in chat context.
+const (
+	FieldLabel    = "label"
+	FieldSummary  = "summary"
+	FieldMetadata = "metadata"
+	FieldRights   = "rights"
+	FieldDates    = "dates"
+	FieldCanvases = "canvases"
+	FieldItems    = "items"
+	FieldRelated  = "related"
+)
+
+// fieldPriority ranks fields when the token budget cannot fit everything.
+// Lower values are kept first.
+var fieldPriority = map[string]int{
+	FieldLabel:    0,
+	FieldSummary:  1,
+	FieldMetadata: 2,
+	FieldDates:    3,
+	FieldRights:   4,
+	FieldRelated:  5,
+	FieldCanvases: 6,
+	FieldItems:    7,
+}
+
+// ContextBuilder renders IIIF resources into compact, structured chat context.
+type ContextBuilder struct {
+	Fields      []string
+	TokenBudget int
+}
+
+// contextBlock is one section of rendered context. Blocks are emitted in
+// document order but admitted to the budget in priority order.
+type contextBlock struct {
+	priority int
+	// header precedes lines; the whole block is dropped if it does not fit.
+	header string
+	lines  []string
+}
+
+// EstimateTokens approximates the token count of s at four characters per token.
+func EstimateTokens(s string) int {
+	return (len([]rune(s)) + 3) / 4
+}
+
+// Build renders the given resources (outermost first) followed by the items
+// in the current list. Resources may be nil.
+func (b ContextBuilder) Build(resources []*iiif.Resource, items []ui.Item) string {
+	return b.BuildWithHits(resources, items, nil)
+}
+
+// BuildWithHits is like Build but also renders manifests retrieved from a
+// semantic index as relevant to the user's question.
+//
+// Each resource, canvas, item and hit is tagged with a reference id in square
+// brackets, which the model is asked to cite; References resolves them.
+func (b ContextBuilder) BuildWithHits(resources []*iiif.Resource, items []ui.Item, hits []index.Hit) string {
+	var blocks []*contextBlock
+	blocks = append(blocks, &contextBlock{
+		priority: -2,
+		header:   citationInstructions,
+	})
+	for depth, res := range resources {
+		if res == nil {
+			continue
+		}
+		blocks = append(blocks, b.resourceBlocks(res, depth)...)
+	}
+	if b.enabled(FieldRelated) && len(hits) > 0 {
+		lines := make([]string, 0, len(hits))
+		for i, hit := range hits {
+			text := strings.Join(strings.Fields(hit.Text), " ")
+			lines = append(lines, fmt.Sprintf("- [%s] %s <%s> (score %.2f): %s", hitRef(i), hit.Label, hit.ID, hit.Score, text))
+		}
+		blocks = append(blocks, &contextBlock{
+			priority: fieldPriority[FieldRelated] * 10,
+			header:   fmt.Sprintf("related manifests from index (%d):", len(hits)),
+			lines:    lines,
+		})
+	}
+	if b.enabled(FieldItems) && len(items) > 0 {
+		lines := make([]string, 0, len(items))
+		for i, item := range items {
+			lines = append(lines, fmt.Sprintf("- [%s] %s: %s <%s>", itemRef(i), item.ItemType, item.Title, item.URL))
+		}
+		blocks = append(blocks, &contextBlock{
+			priority: fieldPriority[FieldItems]*10 + len(resources),
+			header:   fmt.Sprintf("items (%d):", len(items)),
+			lines:    lines,
+		})
+	}
+
+	if len(blocks) == 1 {
+		return ""
+	}
+	return b.fit(blocks)
+}
+
+func (b ContextBuilder) enabled(field string) bool {
+	if len(b.Fields) == 0 {
+		return true
+	}
+	for _, f := range b.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceBlocks renders each enabled field of res as a separate block. Outer
+// resources rank below inner ones for the same field, so the manifest being
+// viewed wins over the collection it came from.
+func (b ContextBuilder) resourceBlocks(res *iiif.Resource, depth int) []*contextBlock {
+	var blocks []*contextBlock
+	add := func(field, header string, lines ...string) {
+		if !b.enabled(field) || (header == "" && len(lines) == 0) {
+			return
+		}
+		blocks = append(blocks, &contextBlock{
+			priority: fieldPriority[field]*10 - depth,
+			header:   header,
+			lines:    lines,
+		})
+	}
+
+	// The heading is always included so the model knows what it is looking at.
+	heading := fmt.Sprintf("# [%s] %s", resourceRef(depth), res.Type)
+	if b.enabled(FieldLabel) {
+		heading += ": " + res.Label
+	}
+	blocks = append(blocks, &contextBlock{
+		priority: -1,
+		header:   heading + "\nid: " + res.ID,
+	})
+
+	if res.Summary != "" {
+		add(FieldSummary, "summary: "+res.Summary)
+	}
+
+	if len(res.Metadata) > 0 {
+		lines := make([]string, 0, len(res.Metadata))
+		for _, e := range res.Metadata {
+			lines = append(lines, fmt.Sprintf("- %s: %s", e.Label, e.Value))
+		}
+		add(FieldMetadata, "metadata:", lines...)
+	}
+
+	if res.NavDate != "" {
+		add(FieldDates, "navDate: "+res.NavDate)
+	}
+
+	var rights []string
+	if res.Rights != "" {
+		rights = append(rights, "rights: "+res.Rights)
+	}
+	if rs := res.RequiredStatement; rs != nil {
+		rights = append(rights, fmt.Sprintf("%s: %s", strings.ToLower(rs.Label), rs.Value))
+	}
+	if len(rights) > 0 {
+		add(FieldRights, strings.Join(rights, "\n"))
+	}
+
+	if len(res.Canvases) > 0 {
+		lines := make([]string, 0, len(res.Canvases))
+		for i, c := range res.Canvases {
+			label := c.Label
+			if label == "" {
+				label = "(untitled)"
+			}
+			lines = append(lines, fmt.Sprintf("- [%s] %s", canvasRef(depth, i), label))
+		}
+		add(FieldCanvases, fmt.Sprintf("canvases (%d):", len(res.Canvases)), lines...)
+	}
+
+	return blocks
+}
+
+// fit admits blocks in priority order until the budget is spent, truncating
+// blocks that do not fit whole and skipping those whose header does not fit,
+// then renders survivors in document order.
+func (b ContextBuilder) fit(blocks []*contextBlock) string {
+	order := make([]*contextBlock, len(blocks))
+	copy(order, blocks)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].priority < order[j].priority })
+
+	remaining := b.TokenBudget
+	kept := make(map[*contextBlock][]string, len(blocks))
+	for _, blk := range order {
+		if b.TokenBudget <= 0 {
+			kept[blk] = blk.lines
+			continue
+		}
+		cost := EstimateTokens(blk.header)
+		if cost > remaining {
+			// A smaller block further down may still fit
+			continue
+		}
+		remaining -= cost
+
+		var lines []string
+		for i, line := range blk.lines {
+			lineCost := EstimateTokens(line) + 1
+			if lineCost <= remaining {
+				remaining -= lineCost
+				lines = append(lines, line)
+				continue
+			}
+			// Drop lines until the note of how many were left out fits too
+			omitted := len(blk.lines) - i
+			for len(lines) > 0 && omittedCost(omitted) > remaining {
+				remaining += EstimateTokens(lines[len(lines)-1]) + 1
+				lines = lines[:len(lines)-1]
+				omitted++
+			}
+			if omittedCost(omitted) <= remaining {
+				remaining -= omittedCost(omitted)
+				lines = append(lines, omittedLine(omitted))
+			}
+			break
+		}
+		kept[blk] = lines
+	}
+
+	var sb strings.Builder
+	for _, blk := range blocks {
+		lines, ok := kept[blk]
+		if !ok {
+			continue
+		}
+		if blk.priority < 0 && sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		if blk.header != "" {
+			sb.WriteString(blk.header)
+			sb.WriteString("\n")
+		}
+		for _, line := range lines {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// omittedLine notes the lines of a block that were left out.
+func omittedLine(n int) string {
+	return fmt.Sprintf("- ... %d more omitted", n)
+}
+
+func omittedCost(n int) int {
+	return EstimateTokens(omittedLine(n)) + 1
+}
@@ -0,0 +1,315 @@
+// File: /loam/internal/app/enrich.go
+
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// enrichmentSchema is the shape of the model's proposed changes.
+const enrichmentSchema = `{
+  "type": "object",
+  "properties": {
+    "summary": {
+      "type": "object",
+      "properties": {
+        "language": {"type": "string", "description": "BCP 47 language tag"},
+        "value": {"type": "string"}
+      },
+      "required": ["language", "value"]
+    },
+    "labels": {
+      "type": "array",
+      "description": "Translations of the manifest label",
+      "items": {
+        "type": "object",
+        "properties": {
+          "language": {"type": "string", "description": "BCP 47 language tag"},
+          "value": {"type": "string"}
+        },
+        "required": ["language", "value"]
+      }
+    },
+    "metadata": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "label": {"type": "string", "description": "Metadata field, e.g. Date or Subject"},
+          "language": {"type": "string", "description": "BCP 47 language tag, or none"},
+          "value": {"type": "string"}
+        },
+        "required": ["label", "language", "value"]
+      }
+    }
+  }
+}`
+
+// enrichmentPrompt asks the model for improvements to a manifest's
+// descriptive metadata.
+const enrichmentPrompt = "Propose improvements to the descriptive metadata of the manifest in the context, for a library catalog. " +
+	"Suggest a summary if it has none or the existing one is weak, translations of its label into English if the label is not in English, " +
+	"and metadata entries (such as Date, Creator, Subject or Language) that are missing or could be improved. " +
+	"Only propose values supported by the manifest, and leave out anything you cannot improve."
+
+// enrichmentProposal is the model's answer to enrichmentPrompt.
+type enrichmentProposal struct {
+	Summary *struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	} `json:"summary"`
+	Labels []struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	} `json:"labels"`
+	Metadata []struct {
+		Label    string `json:"label"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	} `json:"metadata"`
+}
+
+// changes lists the proposal as manifest field changes.
+func (p enrichmentProposal) changes() []iiif.FieldChange {
+	var changes []iiif.FieldChange
+	for _, l := range p.Labels {
+		changes = append(changes, iiif.FieldChange{Field: iiif.PatchLabel, Language: l.Language, New: l.Value})
+	}
+	if s := p.Summary; s != nil {
+		changes = append(changes, iiif.FieldChange{Field: iiif.PatchSummary, Language: s.Language, New: s.Value})
+	}
+	for _, e := range p.Metadata {
+		changes = append(changes, iiif.FieldChange{Field: iiif.PatchMetadata, Label: e.Label, Language: e.Language, New: e.Value})
+	}
+	return changes
+}
+
+// EnrichmentMsg carries the changes proposed for a manifest, or the error
+// that prevented them.
+type EnrichmentMsg struct {
+	Data    []byte // The manifest the changes apply to
+	Changes []iiif.FieldChange
+	Err     error
+}
+
+// EnrichmentReview holds proposed changes while the user accepts or rejects them.
+type EnrichmentReview struct {
+	Data     []byte
+	Label    string
+	Changes  []iiif.FieldChange
+	Accepted []bool
+	Cursor   int
+
+	// replacing is the existing file the next w replaces, once warned.
+	replacing string
+}
+
+// ProposeEnrichment asks the chat model for changes to the manifest in data.
+func ProposeEnrichment(data []byte, builder ContextBuilder) tea.Cmd {
+	return func() tea.Msg {
+		if !iiif.IsPresentation3(data) {
+			return EnrichmentMsg{Err: fmt.Errorf("only Presentation 3 manifests can be enriched")}
+		}
+		res, err := iiif.ParseResource(data)
+		if err != nil {
+			return EnrichmentMsg{Err: err}
+		}
+		cs, err := chatService()
+		if err != nil {
+			return EnrichmentMsg{Err: err}
+		}
+
+		schema := OutputSchema{Name: "enrichment"}
+		if err := json.Unmarshal([]byte(enrichmentSchema), &schema.Schema); err != nil {
+			return EnrichmentMsg{Err: err}
+		}
+		answer, err := cs.SendStructuredSync(enrichmentPrompt, builder.Build([]*iiif.Resource{res}, nil), schema, 2, nil)
+		if err != nil {
+			return EnrichmentMsg{Err: err}
+		}
+
+		var proposal enrichmentProposal
+		if err := json.Unmarshal(answer, &proposal); err != nil {
+			return EnrichmentMsg{Err: fmt.Errorf("failed to read proposed changes: %w", err)}
+		}
+		changes, err := iiif.DiffChanges(data, proposal.changes())
+		return EnrichmentMsg{Data: data, Changes: changes, Err: err}
+	}
+}
+
+// startEnrichment asks for proposed changes to the manifest in the detail pane.
+func (m *Model) startEnrichment() tea.Cmd {
+	if m.DetailResource == nil || m.DetailData == nil {
+		m.Status = "Wait for the manifest to load before enriching it."
+		return nil
+	}
+	m.Status = "Asking the model for metadata changes..."
+	m.Loading = true
+	return tea.Batch(ProposeEnrichment(m.DetailData, m.ContextBuilder), m.Spinner.Tick)
+}
+
+// showEnrichment opens the review of proposed changes.
+func (m *Model) showEnrichment(msg EnrichmentMsg) {
+	m.Loading = false
+	switch {
+	case msg.Err != nil:
+		m.Status = "Enrichment failed: " + msg.Err.Error()
+	case len(msg.Changes) == 0:
+		m.Status = "The model proposed no changes."
+	default:
+		label := ""
+		if res, err := iiif.ParseResource(msg.Data); err == nil {
+			label = res.Label
+		}
+		m.Enrichment = &EnrichmentReview{
+			Data:     msg.Data,
+			Label:    label,
+			Changes:  msg.Changes,
+			Accepted: make([]bool, len(msg.Changes)),
+		}
+		m.Status = fmt.Sprintf("%d proposed changes. Accept the ones to keep, then press w to save.", len(msg.Changes))
+	}
+}
+
+// updateEnrichment handles keys while the enrichment review is open.
+func (m *Model) updateEnrichment(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	r := m.Enrichment
+	if msg.String() != "w" {
+		r.replacing = ""
+	}
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.Enrichment = nil
+		m.Status = "Discarded proposed changes."
+	case "up", "k":
+		if r.Cursor > 0 {
+			r.Cursor--
+		}
+	case "down", "j":
+		if r.Cursor < len(r.Changes)-1 {
+			r.Cursor++
+		}
+	case " ":
+		r.Accepted[r.Cursor] = !r.Accepted[r.Cursor]
+	case "y":
+		r.Accepted[r.Cursor] = true
+		if r.Cursor < len(r.Changes)-1 {
+			r.Cursor++
+		}
+	case "n":
+		r.Accepted[r.Cursor] = false
+		if r.Cursor < len(r.Changes)-1 {
+			r.Cursor++
+		}
+	case "Y":
+		for i := range r.Accepted {
+			r.Accepted[i] = true
+		}
+	case "N":
+		for i := range r.Accepted {
+			r.Accepted[i] = false
+		}
+	case "w":
+		path, err := r.Path()
+		if err != nil {
+			m.Status = "Error: " + err.Error()
+			return m, nil
+		}
+		// Saving the same manifest again replaces the earlier file, so ask first
+		_, statErr := os.Stat(path)
+		exists := statErr == nil
+		if exists && r.replacing != path {
+			r.replacing = path
+			m.Status = path + " already exists. Press w again to replace it."
+			return m, nil
+		}
+		if err := r.Save(path); err != nil {
+			m.Status = "Error: " + err.Error()
+			return m, nil
+		}
+		m.Enrichment = nil
+		m.Status = "Wrote patched manifest to " + path
+		if exists {
+			m.Status = "Replaced " + path + " with the patched manifest"
+		}
+	}
+	return m, nil
+}
+
+// Path is where Save writes the patched manifest: a file in the current
+// directory named after the manifest's id.
+func (r *EnrichmentReview) Path() (string, error) {
+	res, err := iiif.ParseResource(r.Data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(res.ID))
+	return fmt.Sprintf("manifest-%s.enriched.json", hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// Save writes the manifest with the accepted changes applied to path.
+func (r *EnrichmentReview) Save(path string) error {
+	var accepted []iiif.FieldChange
+	for i, c := range r.Changes {
+		if r.Accepted[i] {
+			accepted = append(accepted, c)
+		}
+	}
+	if len(accepted) == 0 {
+		return fmt.Errorf("no changes accepted")
+	}
+	patched, err := iiif.PatchManifest(r.Data, accepted)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(patched, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// enrichmentView renders the proposed changes as a diff against the manifest.
+func (m *Model) enrichmentView() string {
+	r := m.Enrichment
+	width := m.Width - 8
+	if width < 20 {
+		width = 20
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+
+	lines := []string{fmt.Sprintf("Proposed changes to %s", r.Label), ""}
+	for i, c := range r.Changes {
+		mark := "[ ]"
+		if r.Accepted[i] {
+			mark = "[x]"
+		}
+		cursor := "  "
+		if i == r.Cursor {
+			cursor = "> "
+		}
+		heading := cursor + mark + " " + c.String()
+		if i == r.Cursor {
+			heading = TitleStyle.Render(heading)
+		}
+		lines = append(lines, heading)
+		if c.Old != "" {
+			lines = append(lines, DiffRemovedStyle.Render(wrap.Render("- "+c.Old)))
+		}
+		lines = append(lines, DiffAddedStyle.Render(wrap.Render("+ "+c.New)), "")
+	}
+	lines = append(lines, HelpStyle.Render(strings.Join([]string{
+		"↑/↓: Move", "Space: Toggle", "y/n: Accept/Reject", "Y/N: All", "w: Write manifest", "Esc: Discard",
+	}, " | ")))
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
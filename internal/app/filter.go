@@ -0,0 +1,62 @@
+// File: /loam/internal/app/filter.go
+
+package app
+
+import (
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cacheMetadata remembers a fetched manifest's metadata and adds it to the
+// manifest's entry in the results list, so the filter can match it, along
+// with its navDate and canvases for sorting.
+func (m *Model) cacheMetadata(res *iiif.Resource) {
+	m.cacheDetails(res)
+	if meta := res.MetadataMap(); meta != nil {
+		m.ItemMetadata[res.ID] = meta
+	}
+	m.applyMetadata()
+}
+
+// applyMetadata adds cached metadata, navDates and canvas counts to the
+// items in the results list that do not have them yet.
+func (m *Model) applyMetadata() {
+	for i, li := range m.List.Items() {
+		item, ok := li.(ui.Item)
+		if !ok {
+			continue
+		}
+		changed := false
+		if meta, ok := m.ItemMetadata[item.URL]; ok && item.Metadata == nil {
+			item.Metadata = meta
+			changed = true
+		}
+		if d, ok := m.ItemDetails[item.URL]; ok && (item.NavDate == "" || item.Canvases == 0) {
+			if item.NavDate == "" && d.NavDate != "" {
+				item.NavDate = d.NavDate
+				changed = true
+			}
+			if item.Canvases == 0 && d.Canvases > 0 {
+				item.Canvases = d.Canvases
+				changed = true
+			}
+		}
+		if changed {
+			m.List.SetItem(i, item)
+		}
+	}
+}
+
+// updateFilterMatches passes filter results to the list being filtered: the
+// history overlay's if it is open, or else the results list.
+func (m *Model) updateFilterMatches(msg list.FilterMatchesMsg) tea.Cmd {
+	var cmd tea.Cmd
+	if m.ShowHistory {
+		m.HistoryList, cmd = m.HistoryList.Update(msg)
+	} else {
+		m.List, cmd = m.List.Update(msg)
+	}
+	return cmd
+}
@@ -0,0 +1,248 @@
+// File: /loam/internal/app/history.go
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/history"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyItem adapts a history entry to the bubbles list.
+type historyItem struct {
+	entry history.Entry
+}
+
+func (i historyItem) Title() string {
+	if i.entry.Title == "" {
+		return i.entry.URL
+	}
+	return i.entry.Title
+}
+
+func (i historyItem) Description() string {
+	return i.entry.Time.Local().Format("2006-01-02 15:04") + " · " + i.entry.URL
+}
+
+func (i historyItem) FilterValue() string {
+	return i.entry.Title + " " + i.entry.URL
+}
+
+// newHistoryList creates the list used by the history overlay.
+func newHistoryList(width, height int) list.Model {
+	l := list.New([]list.Item{}, list.NewDefaultDelegate(), width, height)
+	l.Title = "History"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	themeList(&l)
+	return l
+}
+
+// loadHistory reads the history of fetched URLs. If it can't be read, an
+// empty history is used and nothing is saved, so the file isn't overwritten.
+func loadHistory() *history.History {
+	path, err := history.DefaultPath()
+	if err != nil {
+		return &history.History{}
+	}
+	h, err := history.Load(path)
+	if err != nil {
+		return &history.History{}
+	}
+	return h
+}
+
+// recordHistory adds a fetched URL to the history. Failing to save the
+// history never fails the fetch.
+func (m *Model) recordHistory(url string, res *iiif.Resource) {
+	if url == "" || m.History.Path == "" {
+		return
+	}
+	m.History.Add(url, res.Label, time.Now().UTC())
+	_ = m.History.Save()
+}
+
+// loadURL fetches url as the root of a new path through the collections.
+func (m *Model) loadURL(url string) tea.Cmd {
+	m.Status = "Fetching data..."
+	m.Loading = true
+	m.Index = nil
+	m.fetchURL = url
+	m.historyCursor = -1
+	return tea.Batch(iiif.FetchData(url), m.Spinner.Tick)
+}
+
+// recallHistory replaces the URL input with an older (up) or newer (down)
+// URL from the history, like a shell. Going past the newest restores what
+// was typed.
+func (m *Model) recallHistory(older bool) {
+	entries := m.History.Entries
+	cursor := m.historyCursor
+	if older {
+		if cursor+1 >= len(entries) {
+			return
+		}
+		if cursor == -1 {
+			m.historyDraft = m.TextArea.Value()
+		}
+		cursor++
+	} else {
+		if cursor == -1 {
+			return
+		}
+		cursor--
+	}
+
+	m.historyCursor = cursor
+	if cursor == -1 {
+		m.TextArea.SetValue(m.historyDraft)
+	} else {
+		m.TextArea.SetValue(entries[cursor].URL)
+	}
+	m.TextArea.CursorEnd()
+}
+
+// openHistory shows the history overlay.
+func (m *Model) openHistory() {
+	items := make([]list.Item, 0, len(m.History.Entries))
+	for _, e := range m.History.Entries {
+		items = append(items, historyItem{entry: e})
+	}
+	m.HistoryList.SetItems(items)
+	m.HistoryList.ResetFilter()
+	m.HistoryList.Select(0)
+	m.ShowHistory = true
+	m.Status = fmt.Sprintf("%d URLs in history", len(items))
+}
+
+// updateHistory handles keys while the history overlay is open.
+func (m *Model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// The filter box takes all keys while it is open
+	if m.HistoryList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.HistoryList, cmd = m.HistoryList.Update(msg)
+		return m, cmd
+	}
+
+	// The key that opened the history closes it too
+	if key.Matches(msg, m.Keys.History) {
+		m.ShowHistory = false
+		m.Status = "Closed history."
+		return m, nil
+	}
+
+	item, _ := m.HistoryList.SelectedItem().(historyItem)
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		if m.HistoryList.FilterState() != list.Unfiltered {
+			m.HistoryList.ResetFilter()
+			return m, nil
+		}
+		m.ShowHistory = false
+		m.Status = "Closed history."
+		return m, nil
+
+	case "enter":
+		if item.entry.URL == "" {
+			return m, nil
+		}
+		m.ShowHistory = false
+		m.ShowDetail = false
+		m.TextArea.SetValue(item.entry.URL)
+		return m, m.loadURL(item.entry.URL)
+
+	case "d":
+		if item.entry.URL == "" {
+			return m, nil
+		}
+		m.History.Remove(item.entry.URL)
+		_ = m.History.Save()
+		// Rebuild the items rather than removing by index, which is a
+		// position among the filtered items while a filter is applied
+		var items []list.Item
+		for _, li := range m.HistoryList.Items() {
+			if hi, ok := li.(historyItem); ok && hi.entry.URL != item.entry.URL {
+				items = append(items, hi)
+			}
+		}
+		m.Status = "Removed from history."
+		return m, m.HistoryList.SetItems(items)
+	}
+
+	var cmd tea.Cmd
+	m.HistoryList, cmd = m.HistoryList.Update(msg)
+	return m, cmd
+}
+
+// navState is everything shown in the results list after a top-level fetch
+// and the collections descended into since, for going back and forward.
+type navState struct {
+	Stack []NavLevel
+	Level NavLevel
+}
+
+func (m *Model) currentNavState() navState {
+	return navState{
+		Stack: append([]NavLevel(nil), m.PrevItemsStack...),
+		Level: m.currentLevel(),
+	}
+}
+
+// goBack returns to the list shown before the last top-level fetch, like a
+// browser's back button; goForward undoes it.
+func (m *Model) goBack() {
+	if len(m.Back) == 0 {
+		m.Status = "Nothing to go back to."
+		return
+	}
+	m.Forward = append(m.Forward, m.currentNavState())
+	state := m.Back[len(m.Back)-1]
+	m.Back = m.Back[:len(m.Back)-1]
+	m.restoreNavState(state)
+	m.Status = "Back to " + state.Level.Label
+}
+
+func (m *Model) goForward() {
+	if len(m.Forward) == 0 {
+		m.Status = "Nothing to go forward to."
+		return
+	}
+	m.Back = append(m.Back, m.currentNavState())
+	state := m.Forward[len(m.Forward)-1]
+	m.Forward = m.Forward[:len(m.Forward)-1]
+	m.restoreNavState(state)
+	m.Status = "Forward to " + state.Level.Label
+}
+
+func (m *Model) restoreNavState(state navState) {
+	m.ShowDetail = false
+	m.DetailResource = nil
+	m.DetailData = nil
+	m.PrevItemsStack = state.Stack
+	m.restoreLevel(state.Level)
+
+	root := state.Level
+	if len(state.Stack) > 0 {
+		root = state.Stack[0]
+	}
+	m.TextArea.SetValue(root.URL)
+
+	// Reload the semantic index the way fetching these levels did
+	m.Index = nil
+	for _, level := range state.Stack {
+		if level.Resource != nil && level.Resource.Type == "Collection" {
+			m.loadIndex(level.Resource.ID)
+		}
+	}
+	if res := state.Level.Resource; res != nil && res.Type == "Collection" {
+		m.loadIndex(res.ID)
+	}
+}
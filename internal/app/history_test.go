@@ -0,0 +1,80 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// settle runs cmd and the filter commands that follow from it. Commands
+// that wait, such as cursor blinks, are skipped.
+func settle(m *Model, cmd tea.Cmd) {
+	cmds := []tea.Cmd{cmd}
+	for len(cmds) > 0 {
+		c := cmds[0]
+		cmds = cmds[1:]
+		if c == nil {
+			continue
+		}
+		done := make(chan tea.Msg, 1)
+		go func() { done <- c() }()
+		var msg tea.Msg
+		select {
+		case msg = <-done:
+		case <-time.After(50 * time.Millisecond):
+			continue
+		}
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			cmds = append(cmds, batch...)
+			continue
+		}
+		if _, ok := msg.(list.FilterMatchesMsg); !ok {
+			continue
+		}
+		_, next := m.Update(msg)
+		cmds = append(cmds, next)
+	}
+}
+
+// press sends keys to the model one at a time, settling after each.
+func press(m *Model, keys ...tea.KeyMsg) {
+	for _, k := range keys {
+		_, cmd := m.Update(k)
+		settle(m, cmd)
+	}
+}
+
+func runes(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+func TestHistoryFilterAndDelete(t *testing.T) {
+	m := chatModel(t, 0)
+	m.ShowChat = false
+	now := time.Now()
+	for i, url := range []string{"https://example.org/alpha", "https://example.org/beta", "https://example.org/gamma"} {
+		m.History.Add(url, "", now.Add(time.Duration(i)*time.Second))
+	}
+	m.openHistory()
+
+	press(m, runes("/"), runes("b"), runes("e"), runes("t"), tea.KeyMsg{Type: tea.KeyEnter})
+	visible := m.HistoryList.VisibleItems()
+	if len(visible) != 1 || visible[0].(historyItem).entry.URL != "https://example.org/beta" {
+		t.Fatalf("filtered history = %v, want beta only", visible)
+	}
+
+	press(m, runes("d"))
+	for _, li := range m.HistoryList.Items() {
+		if li.(historyItem).entry.URL == "https://example.org/beta" {
+			t.Error("beta is still listed after deleting it")
+		}
+	}
+	if got := len(m.HistoryList.Items()); got != 2 {
+		t.Errorf("%d entries listed after deleting one of 3", got)
+	}
+	for _, e := range m.History.Entries {
+		if e.URL == "https://example.org/beta" {
+			t.Error("beta is still in the history after deleting it")
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// File: /loam/internal/app/keys.go
+
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// KeyMap holds the TUI's key bindings. Each binding can be changed in the
+// "keys" section of config.json under its action name; see actions.
+type KeyMap struct {
+	// Everywhere, including the chat panel
+	Help, NewTab, CloseTab, NextTab, PrevTab, RenameTab, ToggleMouse key.Binding
+	SwitchTab                                                        key.Binding // alt+1-9, fixed; one key per tab
+
+	// Everywhere outside the chat panel
+	Quit, ToggleChat, History, Bookmarks, NavBack, NavForward key.Binding
+
+	// URL input
+	SwitchFocus, Load, RecallOlder, RecallNewer key.Binding
+
+	// Results list
+	Open, Back, Filter, Search, OpenBrowser, Bookmark, Sort, Group key.Binding
+	JumpLevel                                                      key.Binding // 1-9, fixed; one key per level
+
+	// Bookmarks, while shown in the results list
+	DeleteBookmark, BookmarkHere, MoveBookmark, RenameFolder key.Binding
+
+	// Detail pane, which also uses Back and Bookmark
+	PrevCanvas, NextCanvas, AskImage, Enrich, BookmarkCanvas key.Binding
+
+	// Chat panel
+	CloseChat, Send, ChatContext, ChatSessions, NewSession, SaveSession key.Binding
+	Templates, Citation, Settings, RetrySetup                           key.Binding
+}
+
+func binding(help string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keys[0], help))
+}
+
+// DefaultKeyMap returns the bindings used when config.json changes none.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Help:        binding("help", "?", "f1"),
+		NewTab:      binding("new tab", "alt+t"),
+		CloseTab:    binding("close tab", "alt+w"),
+		NextTab:     binding("next tab", "alt+n"),
+		PrevTab:     binding("previous tab", "alt+p"),
+		RenameTab:   binding("rename tab", "alt+r"),
+		ToggleMouse: binding("mouse capture", "alt+m"),
+		SwitchTab: key.NewBinding(
+			key.WithKeys("alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9"),
+			key.WithHelp("alt+1-9", "go to tab"),
+		),
+
+		Quit:       binding("quit", "ctrl+c"),
+		ToggleChat: binding("chat", "c", "C"),
+		History:    binding("history", "ctrl+r"),
+		Bookmarks:  binding("bookmarks", "ctrl+b"),
+		NavBack:    binding("back", "[", "alt+left"),
+		NavForward: binding("forward", "]", "alt+right"),
+
+		SwitchFocus: binding("switch focus", "tab"),
+		Load:        binding("fetch URL", "enter"),
+		RecallOlder: binding("older URL", "up"),
+		RecallNewer: binding("newer URL", "down"),
+
+		Open:        binding("open", "enter"),
+		Back:        binding("up a level", "esc"),
+		Filter:      binding("filter", "/"),
+		Search:      binding("semantic search", "s", "S"),
+		OpenBrowser: binding("open in browser", "o", "O"),
+		Bookmark:    binding("bookmark", "b"),
+		Sort:        binding("sort", "t"),
+		Group:       binding("group", "T"),
+		JumpLevel: key.NewBinding(
+			key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			key.WithHelp("1-9", "jump to level"),
+		),
+
+		DeleteBookmark: binding("delete", "d"),
+		BookmarkHere:   binding("add bookmarks here", "w"),
+		MoveBookmark:   binding("move to folder", "m"),
+		RenameFolder:   binding("rename folder", "r"),
+
+		PrevCanvas:     binding("previous canvas", "left", "h"),
+		NextCanvas:     binding("next canvas", "right", "l"),
+		AskImage:       binding("ask about image", "a", "A"),
+		Enrich:         binding("propose metadata", "e", "E"),
+		BookmarkCanvas: binding("bookmark canvas", "B"),
+
+		CloseChat:    binding("close chat", "esc", "ctrl+c"),
+		Send:         binding("send", "enter"),
+		ChatContext:  binding("show context", "ctrl+o"),
+		ChatSessions: binding("sessions", "ctrl+r"),
+		NewSession:   binding("new session", "ctrl+n"),
+		SaveSession:  binding("save session", "ctrl+s"),
+		Templates:    binding("templates", "ctrl+p"),
+		Citation:     binding("select citation", "ctrl+g"),
+		Settings:     binding("inference settings", "ctrl+e"),
+		RetrySetup:   binding("check setup", "ctrl+t"),
+	}
+}
+
+// keyAction names a binding for config.json.
+type keyAction struct {
+	Name    string
+	Binding *key.Binding
+}
+
+// actions lists the bindings that can be changed, by name.
+func (k *KeyMap) actions() []keyAction {
+	return []keyAction{
+		{"help", &k.Help},
+		{"new_tab", &k.NewTab},
+		{"close_tab", &k.CloseTab},
+		{"next_tab", &k.NextTab},
+		{"prev_tab", &k.PrevTab},
+		{"rename_tab", &k.RenameTab},
+		{"toggle_mouse", &k.ToggleMouse},
+		{"quit", &k.Quit},
+		{"toggle_chat", &k.ToggleChat},
+		{"history", &k.History},
+		{"bookmarks", &k.Bookmarks},
+		{"nav_back", &k.NavBack},
+		{"nav_forward", &k.NavForward},
+		{"switch_focus", &k.SwitchFocus},
+		{"load", &k.Load},
+		{"recall_older", &k.RecallOlder},
+		{"recall_newer", &k.RecallNewer},
+		{"open", &k.Open},
+		{"back", &k.Back},
+		{"filter", &k.Filter},
+		{"search", &k.Search},
+		{"open_browser", &k.OpenBrowser},
+		{"bookmark", &k.Bookmark},
+		{"sort", &k.Sort},
+		{"group", &k.Group},
+		{"delete_bookmark", &k.DeleteBookmark},
+		{"bookmark_here", &k.BookmarkHere},
+		{"move_bookmark", &k.MoveBookmark},
+		{"rename_folder", &k.RenameFolder},
+		{"prev_canvas", &k.PrevCanvas},
+		{"next_canvas", &k.NextCanvas},
+		{"ask_image", &k.AskImage},
+		{"enrich", &k.Enrich},
+		{"bookmark_canvas", &k.BookmarkCanvas},
+		{"close_chat", &k.CloseChat},
+		{"send", &k.Send},
+		{"chat_context", &k.ChatContext},
+		{"chat_sessions", &k.ChatSessions},
+		{"new_session", &k.NewSession},
+		{"save_session", &k.SaveSession},
+		{"templates", &k.Templates},
+		{"citation", &k.Citation},
+		{"settings", &k.Settings},
+		{"retry_setup", &k.RetrySetup},
+	}
+}
+
+// NewKeyMap returns the default bindings with those named in overrides
+// replaced; an empty list of keys unbinds an action. Unknown actions, and
+// keys bound to two actions that work in the same place, are reported in
+// the error, but the rest of the bindings are still applied.
+func NewKeyMap(overrides map[string][]string) (KeyMap, error) {
+	k := DefaultKeyMap()
+	byName := make(map[string]*key.Binding)
+	for _, a := range k.actions() {
+		byName[a.Name] = a.Binding
+	}
+
+	var problems []string
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, ok := byName[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown action %q", name))
+			continue
+		}
+		keys := overrides[name]
+		b.SetKeys(keys...)
+		b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+		b.SetEnabled(len(keys) > 0)
+	}
+
+	// Bindings shared by several scopes would report a conflict in each
+	reported := make(map[string]bool)
+	for _, scope := range k.scopes() {
+		bound := make(map[string]string)
+		for _, b := range scope {
+			for _, s := range b.Keys() {
+				if other, ok := bound[s]; ok && other != b.Help().Desc {
+					problem := fmt.Sprintf("%q is bound to both %s and %s", s, other, b.Help().Desc)
+					if !reported[problem] {
+						reported[problem] = true
+						problems = append(problems, problem)
+					}
+				}
+				bound[s] = b.Help().Desc
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return k, fmt.Errorf("key bindings: %s", strings.Join(problems, "; "))
+	}
+	return k, nil
+}
+
+// scopes groups the bindings that are active at the same time, so no key
+// may be bound twice within one.
+func (k KeyMap) scopes() [][]key.Binding {
+	always := []key.Binding{k.Help, k.NewTab, k.CloseTab, k.NextTab, k.PrevTab, k.RenameTab, k.SwitchTab, k.ToggleMouse}
+	outside := append(always[:len(always):len(always)], k.Quit, k.ToggleChat, k.History, k.Bookmarks, k.NavBack, k.NavForward)
+	scope := func(base []key.Binding, bindings ...key.Binding) []key.Binding {
+		return append(base[:len(base):len(base)], bindings...)
+	}
+	return [][]key.Binding{
+		scope(outside, k.SwitchFocus, k.Load, k.RecallOlder, k.RecallNewer),
+		scope(outside, k.Open, k.Back, k.Filter, k.Search, k.OpenBrowser, k.Bookmark, k.Sort, k.Group, k.JumpLevel, k.SwitchFocus,
+			k.DeleteBookmark, k.BookmarkHere, k.MoveBookmark, k.RenameFolder),
+		scope(outside, k.Back, k.PrevCanvas, k.NextCanvas, k.AskImage, k.Enrich, k.Bookmark, k.BookmarkCanvas),
+		scope(always, k.CloseChat, k.Send, k.ChatContext, k.ChatSessions, k.NewSession, k.SaveSession,
+			k.Templates, k.Citation, k.Settings, k.RetrySetup),
+	}
+}
+
+// withHelp returns b described as desc, for bindings that do different
+// things in different places.
+func withHelp(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// keyIndex returns the position of msg among the keys of b, which for
+// bindings such as JumpLevel is the level or tab the key stands for, or -1.
+func keyIndex(b key.Binding, msg tea.KeyMsg) int {
+	for i, k := range b.Keys() {
+		if k == msg.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// printable reports whether a key types a character.
+func printable(msg tea.KeyMsg) bool {
+	return (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) && !msg.Alt
+}
+
+// typing reports whether msg types into the focused text input, in which
+// case it mustn't trigger a binding.
+func (m *Model) typing(msg tea.KeyMsg) bool {
+	if !printable(msg) {
+		return false
+	}
+	switch {
+	case m.ShowTabPrompt, m.ShowSearch, m.ShowFolderPrompt:
+		return true
+	case m.ShowHistory:
+		return m.HistoryList.FilterState() == list.Filtering
+	case m.Enrichment != nil:
+		return false
+	case m.ShowChat:
+		switch {
+		case m.Chat.ShowSessions:
+			return m.Chat.Sessions.FilterState() == list.Filtering
+		case m.Chat.ShowTemplates:
+			return m.Chat.Templates.FilterState() == list.Filtering
+		}
+		return true
+	case !m.InList:
+		return true
+	}
+	return !m.ShowDetail && m.List.FilterState() == list.Filtering
+}
+
+// helpSection is a titled group of bindings in the help overlay.
+type helpSection struct {
+	Title    string
+	Bindings []key.Binding
+}
+
+// helpSections lists the bindings that work where the focus is, or nil in
+// the overlays that show their own keys.
+func (m *Model) helpSections() []helpSection {
+	k := m.Keys
+	tabs := helpSection{"Tabs", []key.Binding{k.NewTab, k.CloseTab, k.NextTab, k.PrevTab, k.SwitchTab, k.RenameTab}}
+	if m.ShowChat {
+		if m.Chat.ShowSessions || m.Chat.ShowTemplates || m.Chat.ShowSettings {
+			return nil
+		}
+		return []helpSection{
+			{"Chat", m.usableAll([]key.Binding{k.Send, k.CloseChat, k.ChatContext, k.ChatSessions, k.NewSession,
+				k.SaveSession, k.Templates, k.Citation, k.Settings, k.RetrySetup, k.ToggleMouse, k.Help})},
+			tabs,
+		}
+	}
+	if m.ShowSearch || m.ShowFolderPrompt || m.ShowHistory || m.Enrichment != nil {
+		return nil
+	}
+
+	everywhere := helpSection{"Everywhere", []key.Binding{k.ToggleChat, k.History, k.Bookmarks, k.NavBack, k.NavForward,
+		k.ToggleMouse, k.Help, k.Quit}}
+	var sections []helpSection
+	switch {
+	case m.ShowDetail:
+		sections = append(sections, helpSection{"Detail", []key.Binding{withHelp(k.Back, "close detail"), k.PrevCanvas,
+			k.NextCanvas, k.AskImage, k.Enrich, withHelp(k.Bookmark, "bookmark manifest"), k.BookmarkCanvas}})
+	case !m.InList:
+		sections = append(sections, helpSection{"URL Input", []key.Binding{k.Load, k.SwitchFocus, k.RecallOlder, k.RecallNewer}})
+	default:
+		lk := m.List.KeyMap
+		sections = append(sections, helpSection{"Results", []key.Binding{k.Open, k.Back, k.JumpLevel, k.Filter, k.Search,
+			k.OpenBrowser, k.Bookmark, k.Sort, k.Group, k.SwitchFocus, lk.CursorUp, lk.CursorDown, lk.PrevPage, lk.NextPage}})
+		if _, ok := m.bookmarksLevel(); ok {
+			sections = append(sections, helpSection{"Bookmarks", []key.Binding{k.DeleteBookmark, k.BookmarkHere,
+				k.MoveBookmark, k.RenameFolder}})
+		}
+	}
+	sections = append(sections, everywhere, tabs)
+	for i := range sections {
+		sections[i].Bindings = m.usableAll(sections[i].Bindings)
+	}
+	return sections
+}
+
+// usable returns b as shown where the focus is: keys that would type into
+// a text input are left out, and b is hidden if that leaves none.
+func (m *Model) usable(b key.Binding) key.Binding {
+	var keys []string
+	for _, k := range b.Keys() {
+		if len([]rune(k)) == 1 && m.typing(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == len(b.Keys()) {
+		return b
+	}
+	if len(keys) == 0 {
+		b.SetEnabled(false)
+	}
+	b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+	return b
+}
+
+func (m *Model) usableAll(bindings []key.Binding) []key.Binding {
+	out := make([]key.Binding, len(bindings))
+	for i, b := range bindings {
+		out[i] = m.usable(b)
+	}
+	return out
+}
+
+// shortHelp lists the most used bindings where the focus is, for the footer.
+func (m *Model) shortHelp() []key.Binding {
+	k := m.Keys
+	var bindings []key.Binding
+	switch {
+	case m.ShowChat:
+		bindings = []key.Binding{k.Send, k.CloseChat, k.ChatSessions, k.Templates, k.Help}
+	case m.ShowDetail:
+		bindings = []key.Binding{withHelp(k.Back, "close detail"), k.PrevCanvas, k.NextCanvas, k.AskImage, k.Help}
+	case !m.InList:
+		bindings = []key.Binding{k.Load, k.SwitchFocus, k.RecallOlder, k.History, k.Help}
+	default:
+		bindings = []key.Binding{k.Open, k.Back, k.Filter, k.Search, k.ToggleChat, k.Help}
+	}
+	return m.usableAll(bindings)
+}
+
+// helpView renders the help overlay.
+func (m *Model) helpView() string {
+	var parts []string
+	for _, s := range m.helpSections() {
+		// Lay each section out in columns of up to six bindings
+		var columns [][]key.Binding
+		for i := 0; i < len(s.Bindings); i += 6 {
+			columns = append(columns, s.Bindings[i:min(i+6, len(s.Bindings))])
+		}
+		parts = append(parts, TitleStyle.Render(s.Title), m.Help.FullHelpView(columns), "")
+	}
+	parts = append(parts, HelpStyle.Render("Press any key to close. Bindings can be changed under \"keys\" in config.json."))
+	return strings.Join(parts, "\n")
+}
+
+// updateHelp closes the help overlay on any key but quit.
+func (m *Model) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, m.Keys.Quit) && !m.ShowChat {
+		return m, tea.Quit
+	}
+	m.ShowHelp = false
+	return m, nil
+}
+
+// chatWelcome is shown in the chat panel before the first message.
+func (m *Model) chatWelcome() string {
+	k := m.Keys
+	keys := func(b key.Binding) string {
+		return "'" + strings.Join(b.Keys(), "' or '") + "'"
+	}
+	return fmt.Sprintf(`Welcome to LoamIIIF Chat!
+Press %s to close the chat panel.
+Press %s to inspect the context sent with each message.
+Press %s to browse saved sessions or %s to start a new one.
+Press %s to pick a prompt template or %s to change inference settings.
+Press %s to select a citation in the latest reply and %s to go to it.
+Press %s to check the chat setup again.`,
+		keys(k.CloseChat), keys(k.ChatContext), keys(k.ChatSessions), keys(k.NewSession),
+		keys(k.Templates), keys(k.Settings), keys(k.Citation), keys(k.Send), keys(k.RetrySetup))
+}
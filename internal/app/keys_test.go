@@ -0,0 +1,26 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestKeyIndex(t *testing.T) {
+	levels := key.NewBinding(key.WithKeys("alt+1", "f2", "3"))
+	tests := []struct {
+		msg  tea.KeyMsg
+		want int
+	}{
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1"), Alt: true}, 0},
+		{tea.KeyMsg{Type: tea.KeyF2}, 1},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")}, 2},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1")}, -1},
+	}
+	for _, tt := range tests {
+		if got := keyIndex(levels, tt.msg); got != tt.want {
+			t.Errorf("%s: level %d, want %d", tt.msg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// File: /loam/internal/app/mock.go
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// mockModelID is the model id reported by the mock provider.
+const mockModelID = "mock"
+
+// MockRule scripts one kind of reply. Rules are tried in order against the
+// latest prompt; the first whose Match pattern matches is used.
+//
+// Reply, Error and the tool name and input are text/templates executed with
+// MockTurn as data.
+type MockRule struct {
+	// Match is a regular expression; an empty pattern matches every prompt.
+	Match string `json:"match,omitempty"`
+
+	// Reply is the assistant's answer.
+	Reply string `json:"reply,omitempty"`
+
+	// Error makes the request fail with this message instead.
+	Error string `json:"error,omitempty"`
+
+	// Tool makes the model call a tool first. Reply is sent once the tool
+	// results come back.
+	Tool *MockToolCall `json:"tool,omitempty"`
+
+	// Chunks splits a streamed reply into this many pieces. By default the
+	// reply streams word by word.
+	Chunks int `json:"chunks,omitempty"`
+}
+
+// MockToolCall is a tool invocation requested by a MockRule. Input may be a
+// JSON object or a JSON string holding the object.
+type MockToolCall struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// MockTurn is the data available to MockRule templates.
+type MockTurn struct {
+	Prompt      string   // The latest text the user sent
+	Groups      []string // Submatches of the rule's Match pattern
+	System      string   // The system prompt, including the chat context
+	ToolResults []string // Results of the tool the rule called
+	Turn        int      // Number of assistant replies so far
+}
+
+// MockScript is the file format read by LoadMockScript.
+type MockScript struct {
+	// ChunkDelayMS pauses between streamed chunks.
+	ChunkDelayMS int        `json:"chunk_delay_ms,omitempty"`
+	Rules        []MockRule `json:"rules"`
+}
+
+// defaultMockRules follow any scripted rules. They give the mock a few
+// commands for exercising each path through the chat loop:
+//
+//	/error <message>        fail the request
+//	/tool <name> <json>     call a tool, then report its result
+//	anything else           echo the prompt
+var defaultMockRules = []MockRule{
+	{
+		Match: `^/error\s*(.*)$`,
+		Error: `{{with index .Groups 1}}{{.}}{{else}}mock error{{end}}`,
+	},
+	{
+		Match: `^/tool\s+(\S+)\s*(.*)$`,
+		Tool:  &MockToolCall{Name: `{{index .Groups 1}}`, Input: json.RawMessage(`"{{with index .Groups 2}}{{.}}{{else}}{}{{end}}"`)},
+		Reply: "The tool returned:\n\n{{range .ToolResults}}{{.}}\n{{end}}",
+	},
+	{
+		Reply: `You said: {{.Prompt}}`,
+	},
+}
+
+// MockProvider is an offline chat provider for demos and testing. It answers
+// deterministically from a script of rules, falling back to echoing the prompt.
+type MockProvider struct {
+	Rules      []MockRule
+	ChunkDelay time.Duration
+}
+
+// NewMockProvider creates a mock provider from the script at path, or with
+// only the default rules if path is empty.
+func NewMockProvider(path string) (*MockProvider, error) {
+	p := &MockProvider{Rules: defaultMockRules, ChunkDelay: 30 * time.Millisecond}
+	if path == "" {
+		return p, nil
+	}
+
+	script, err := LoadMockScript(path)
+	if err != nil {
+		return nil, err
+	}
+	p.Rules = append(script.Rules, defaultMockRules...)
+	p.ChunkDelay = time.Duration(script.ChunkDelayMS) * time.Millisecond
+	return p, nil
+}
+
+// LoadMockScript reads a mock script from a JSON file.
+func LoadMockScript(path string) (*MockScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock script: %w", err)
+	}
+	var script MockScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse mock script %s: %w", path, err)
+	}
+	for i, rule := range script.Rules {
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			return nil, fmt.Errorf("invalid match pattern in mock rule %d: %w", i+1, err)
+		}
+	}
+	return &script, nil
+}
+
+// ModelID returns the mock model id.
+func (p *MockProvider) ModelID() string { return mockModelID }
+
+// Models lists the mock model.
+func (p *MockProvider) Models(ctx context.Context) ([]string, error) {
+	return []string{mockModelID}, nil
+}
+
+// Converse answers with the first rule matching the latest prompt.
+func (p *MockProvider) Converse(ctx context.Context, req ChatRequest, onChunk func(string)) (*ChatResponse, error) {
+	turn := MockTurn{}
+	for _, s := range req.System {
+		turn.System += s.Text
+	}
+	for _, msg := range req.Messages {
+		if msg.Role == "assistant" {
+			turn.Turn++
+			continue
+		}
+		if text := msg.Text(); text != "" {
+			turn.Prompt = text
+		}
+	}
+
+	// A trailing tool result answers the tool call of the previous reply
+	var toolResults []string
+	if n := len(req.Messages); n > 0 {
+		for _, block := range req.Messages[n-1].Content {
+			if block.ToolResult != nil && len(block.ToolResult.Content) > 0 {
+				toolResults = append(toolResults, block.ToolResult.Content[0].Text)
+			}
+		}
+	}
+
+	rule, groups, err := p.match(turn.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	turn.Groups = groups
+	turn.ToolResults = toolResults
+
+	if rule.Error != "" {
+		message, err := renderMock(rule.Error, turn)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.New(message)
+	}
+
+	response := &ChatResponse{StopReason: "end_turn"}
+	response.Output.Message.Role = "assistant"
+
+	if rule.Tool != nil && toolResults == nil {
+		use, err := p.toolUse(rule.Tool, turn)
+		if err != nil {
+			return nil, err
+		}
+		response.StopReason = "tool_use"
+		response.Output.Message.Content = []ContentBlock{{ToolUse: use}}
+		return response, nil
+	}
+
+	reply, err := renderMock(rule.Reply, turn)
+	if err != nil {
+		return nil, err
+	}
+	if reply == "" {
+		reply = "(no reply)"
+	}
+	if onChunk != nil {
+		for _, chunk := range splitChunks(reply, rule.Chunks) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(p.ChunkDelay):
+			}
+			onChunk(chunk)
+		}
+	}
+	response.Output.Message.Content = []ContentBlock{{Text: reply}}
+	return response, nil
+}
+
+// match returns the first rule matching prompt and its submatches.
+func (p *MockProvider) match(prompt string) (MockRule, []string, error) {
+	for _, rule := range p.Rules {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return MockRule{}, nil, fmt.Errorf("invalid match pattern %q: %w", rule.Match, err)
+		}
+		if groups := re.FindStringSubmatch(strings.TrimSpace(prompt)); groups != nil {
+			return rule, groups, nil
+		}
+	}
+	return MockRule{}, nil, fmt.Errorf("no mock rule matches %q", prompt)
+}
+
+// toolUse renders the tool call requested by a rule.
+func (p *MockProvider) toolUse(call *MockToolCall, turn MockTurn) (*ToolUse, error) {
+	name, err := renderMock(call.Name, turn)
+	if err != nil {
+		return nil, err
+	}
+
+	input := string(call.Input)
+	var quoted string
+	if json.Unmarshal(call.Input, &quoted) == nil {
+		input = quoted
+	}
+	if input, err = renderMock(input, turn); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(input) == "" {
+		input = "{}"
+	}
+	if !json.Valid([]byte(input)) {
+		return nil, fmt.Errorf("mock tool input is not valid JSON: %s", input)
+	}
+
+	return &ToolUse{
+		ToolUseID: fmt.Sprintf("mock-%d-%s", turn.Turn+1, name),
+		Name:      name,
+		Input:     json.RawMessage(input),
+	}, nil
+}
+
+// renderMock executes a rule template.
+func renderMock(text string, turn MockTurn) (string, error) {
+	tmpl, err := template.New("mock").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse mock template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, turn); err != nil {
+		return "", fmt.Errorf("failed to render mock template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// splitChunks splits text into n roughly equal pieces on word boundaries, or
+// into single words if n is not positive. Joining the pieces gives back text.
+func splitChunks(text string, n int) []string {
+	var words []string
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || (text[i] == ' ' && text[i-1] != ' ') {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if n <= 0 || n >= len(words) {
+		return words
+	}
+
+	chunks := make([]string, 0, n)
+	per := (len(words) + n - 1) / n
+	for i := 0; i < len(words); i += per {
+		end := i + per
+		if end > len(words) {
+			end = len(words)
+		}
+		chunks = append(chunks, strings.Join(words[i:end], ""))
+	}
+	return chunks
+}
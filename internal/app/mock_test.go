@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// userMessage is a user turn with text.
+func userMessage(text string) Message {
+	return Message{Role: "user", Content: []ContentBlock{{Text: text}}}
+}
+
+// writeMockScript saves script to a temporary file and returns its path.
+func writeMockScript(t *testing.T, script MockScript) string {
+	t.Helper()
+	data, err := json.Marshal(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "mock.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMockRules(t *testing.T) {
+	p, err := NewMockProvider(writeMockScript(t, MockScript{Rules: []MockRule{
+		{Match: `^weather in (\w+)$`, Reply: `Sunny in {{index .Groups 1}} (turn {{.Turn}})`},
+		{Match: `context`, Reply: `{{if eq .System "Loam context"}}seen{{else}}missing{{end}}`},
+	}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ChunkDelay = 0
+
+	tests := []struct {
+		name     string
+		messages []Message
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "first matching rule with submatches",
+			messages: []Message{userMessage("weather in Evanston")},
+			want:     "Sunny in Evanston (turn 0)",
+		},
+		{
+			name: "turn counts earlier replies",
+			messages: []Message{userMessage("hi"), {Role: "assistant", Content: []ContentBlock{{Text: "hello"}}},
+				userMessage("weather in Chicago")},
+			want: "Sunny in Chicago (turn 1)",
+		},
+		{
+			name:     "system prompt",
+			messages: []Message{userMessage("what is in the context?")},
+			want:     "seen",
+		},
+		{
+			name:     "default rules echo",
+			messages: []Message{userMessage("anything else")},
+			want:     "You said: anything else",
+		},
+		{
+			name:     "default error rule",
+			messages: []Message{userMessage("/error throttled")},
+			wantErr:  "throttled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ChatRequest{Messages: tt.messages, System: []TextContent{{Text: "Loam context"}}}
+			resp, err := p.Converse(context.Background(), req, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Output.Message.Text(); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if resp.StopReason != "end_turn" {
+				t.Errorf("stop reason = %q, want end_turn", resp.StopReason)
+			}
+		})
+	}
+}
+
+func TestMockToolCall(t *testing.T) {
+	p, err := NewMockProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ChunkDelay = 0
+
+	history := []Message{userMessage(`/tool fetch_manifest {"url": "https://example.org/m"}`)}
+	resp, err := p.Converse(context.Background(), ChatRequest{Messages: history}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uses := resp.Output.Message.ToolUses()
+	if resp.StopReason != "tool_use" || len(uses) != 1 {
+		t.Fatalf("got stop reason %q with %d tool uses, want one tool use", resp.StopReason, len(uses))
+	}
+	if uses[0].Name != "fetch_manifest" || string(uses[0].Input) != `{"url": "https://example.org/m"}` {
+		t.Errorf("tool use = %s %s", uses[0].Name, uses[0].Input)
+	}
+
+	// The reply after the tool results reports them
+	history = append(history, resp.Output.Message, Message{Role: "user", Content: []ContentBlock{{
+		ToolResult: &ToolResult{ToolUseID: uses[0].ToolUseID, Content: []TextContent{{Text: "a manifest"}}},
+	}}})
+	resp, err = p.Converse(context.Background(), ChatRequest{Messages: history}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Output.Message.Text(); !strings.Contains(got, "a manifest") {
+		t.Errorf("reply = %q, want the tool result", got)
+	}
+}
+
+func TestMockStreamsChunksInOrder(t *testing.T) {
+	p, err := NewMockProvider(writeMockScript(t, MockScript{Rules: []MockRule{
+		{Match: `^three$`, Reply: "one two three four five six", Chunks: 3},
+	}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		prompt string
+		want   []string
+	}{
+		{"three", []string{"one two", " three four", " five six"}},
+		{"a b", []string{"You", " said:", " a", " b"}},
+	} {
+		var chunks []string
+		resp, err := p.Converse(context.Background(), ChatRequest{Messages: []Message{userMessage(tt.prompt)}},
+			func(text string) { chunks = append(chunks, text) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(chunks, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: chunks = %q, want %q", tt.prompt, chunks, tt.want)
+		}
+		if got := strings.Join(chunks, ""); got != resp.Output.Message.Text() {
+			t.Errorf("%s: chunks join to %q, reply is %q", tt.prompt, got, resp.Output.Message.Text())
+		}
+	}
+}
+
+func TestLoadMockScriptRejectsBadPattern(t *testing.T) {
+	path := writeMockScript(t, MockScript{Rules: []MockRule{{Match: `(`}}})
+	if _, err := LoadMockScript(path); err == nil || !strings.Contains(err.Error(), "rule 1") {
+		t.Errorf("error = %v, want an invalid pattern in rule 1", err)
+	}
+}
@@ -5,10 +5,16 @@ package app
 import (
 	"sync"
 
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/history"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/index"
 	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -23,6 +29,68 @@ type ChatModel struct {
 
 	// New Field for Context
 	Context string
+
+	// ShowContext swaps the message history for the exact context being sent.
+	ShowContext bool
+
+	// History is the conversation sent to the model, including tool calls and
+	// results. Steps counts tool rounds for the current prompt, and turnStart
+	// marks where it began so a failed turn can be rolled back.
+	History   []Message
+	Steps     int
+	turnStart int
+
+	// TurnUsage totals the requests made for the current prompt.
+	TurnUsage UsageTotals
+
+	// Streaming holds the reply text received so far while it streams in.
+	Streaming string
+
+	// InFlight is set from sending a prompt until its final reply or error
+	// arrives, tool rounds included.
+	InFlight bool
+
+	// Setup is the result of the last check of the chat backend, nil until
+	// the panel is first opened.
+	Setup         *ChatSetupMsg
+	CheckingSetup bool
+
+	// PendingImage is attached to the next message the user sends.
+	PendingImage *CanvasImageMsg
+
+	// Hits are the manifests retrieved from the semantic index for the
+	// current prompt.
+	Hits []index.Hit
+
+	// References resolves the reference ids in Context, and sentReferences
+	// those in the context last sent, which replies are cited against since
+	// ids are positions that change as the list does. Citations are the
+	// references cited by the latest reply, and Cited is the one selected
+	// with ctrl+g, or -1.
+	References     map[string]Reference
+	sentReferences map[string]Reference
+	Citations      []Reference
+	Cited          int
+	citedReply     string
+	citedMessage   int
+
+	// Session is the saved session the conversation belongs to, and
+	// Sessions the browser used to resume or export earlier ones.
+	Session      *ChatSession
+	Sessions     list.Model
+	ShowSessions bool
+
+	// Templates is the palette of reusable prompt templates.
+	Templates     list.Model
+	ShowTemplates bool
+
+	// Inference holds the sampling parameters sent with each message, edited
+	// in the settings overlay.
+	Inference     InferenceConfig
+	Settings      []textinput.Model
+	SettingsFocus int
+	SettingsErr   string
+	ShowSettings  bool
 }
 
 // Model is the main application model.
@@ -39,8 +107,93 @@ type Model struct {
 	ShowDetail   bool
 	SelectedItem ui.Item
 
-	// Stack for item slices (so you can go back)
-	PrevItemsStack [][]list.Item
+	// PrevItemsStack holds the lists above the one being shown, from the
+	// root down, so you can go back; Level names the list being shown.
+	PrevItemsStack []NavLevel
+	Level          NavLevel
+	pendingLevel   *NavLevel // Pushed once the collection being fetched arrives
+	fetchURL       string    // The URL being fetched, for the history
+
+	// History of fetched URLs, recalled with up/down in the URL input and
+	// browsed in an overlay; Back and Forward hold the lists shown before
+	// and after top-level fetches.
+	History       *history.History
+	HistoryList   list.Model
+	ShowHistory   bool
+	historyCursor int    // Entry recalled into the URL input, -1 for none
+	historyDraft  string // What was typed before recalling
+	Back          []navState
+	Forward       []navState
+
+	// Bookmarks are pinned into BookmarkFolder, and managed while shown in
+	// the list; FolderPrompt asks for a folder name when moving a bookmark
+	// or renaming a folder.
+	BookmarkFolder   string
+	FolderPrompt     textinput.Model
+	ShowFolderPrompt bool
+	folderAction     string // "m" to move, "r" to rename
+	pendingCanvas    string // Canvas to highlight once the detail manifest loads
+
+	// Resource is the most recently fetched collection or manifest, and
+	// DetailResource the manifest opened in the detail pane, if any.
+	Resource       *iiif.Resource
+	DetailResource *iiif.Resource
+	DetailData     []byte // Raw JSON of DetailResource
+	CanvasIndex    int    // Canvas highlighted in the detail pane
+	ContextBuilder ContextBuilder
+	MaxToolSteps   int
+
+	// Tabs are the open browsing sessions. The active one's state is held
+	// in the fields of the model; see Tab. TabPrompt renames it.
+	Tabs          []*Tab
+	ActiveTab     int
+	TabPrompt     textinput.Model
+	ShowTabPrompt bool
+	RestoreTabs   bool   // Save tabs on exit and open them on the next run
+	startURL      string // Fetched by Init, for a restored tab
+
+	// Split shows the list and a preview of the highlighted item side by
+	// side, when the terminal is at least SplitWidth wide. Previews caches
+	// the most recently fetched previews by URL.
+	Split        bool
+	SplitWidth   int
+	SplitRatio   float64
+	PreviewWidth int
+	Previews     *previewCache
+	previewURL   string // The item the preview is for
+	previewErr   error  // Why the preview of previewURL failed
+
+	// Keys are the key bindings, listed for where the focus is in the
+	// footer and, with ShowHelp, in the help overlay.
+	Keys     KeyMap
+	Help     help.Model
+	ShowHelp bool
+
+	// Mouse is on while the mouse is captured to scroll and click the zones
+	// recorded by the last render; see mouse.go.
+	Mouse     bool
+	zones     []zone
+	lastClick click
+
+	// Enrichment reviews changes to the detail manifest proposed by the model.
+	Enrichment *EnrichmentReview
+
+	// ItemMetadata caches the metadata of manifests, by URL, for filtering
+	// the results list. It is filled from the semantic index and from
+	// manifests opened in the detail pane.
+	ItemMetadata map[string]map[string]string
+
+	// Arrangements are how each list is sorted and grouped, by URL, saved
+	// in sorting.json. ItemDetails caches the navDates and canvas counts of
+	// fetched resources, by URL, for sorting.
+	Arrangements map[string]ui.Arrangement
+	ItemDetails  map[string]itemDetails
+
+	// Semantic index for the current collection and the search box over it
+	Index            *index.Index
+	EmbeddingsConfig config.EmbeddingsConfig
+	ShowSearch       bool
+	Search           textinput.Model
 
 	// --- New Chat Fields ---
 	ShowChat        bool // Are we currently showing the chat panel?
@@ -62,23 +215,28 @@ func InitialChatModel() ChatModel {
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle() // remove cursor line styling
 	ta.ShowLineNumbers = false
 	ta.KeyMap.InsertNewline.SetEnabled(false)
+	themeTextArea(&ta)
 
 	vp := viewport.New(50, 10) // Increased height for more messages
-	vp.SetContent(`Welcome to LoamIIIF Chat!
-Press 'esc' or 'c' to close the chat panel.`)
 
 	return ChatModel{
 		Viewport:    vp,
 		Messages:    []string{},
 		TextArea:    ta,
-		SenderStyle: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")),
+		SenderStyle: SenderStyle,
 		Err:         nil,
 		Context:     "", // Initialize context as empty
+		Sessions:    newSessionList(50, 10),
+		Templates:   newTemplateList(50, 10),
+		Cited:       -1,
 	}
 }
 
 // InitialModel initializes the main Model.
-func InitialModel() *Model {
+func InitialModel(cfg config.Config) *Model {
+	// Everything below is drawn in the theme's colors
+	themeErr := loadTheme(cfg.UI.Theme)
+
 	// Existing initialization of the text input
 	ta := textarea.New()
 	ta.Placeholder = "Enter IIIF URL..."
@@ -92,14 +250,26 @@ func InitialModel() *Model {
 	ta.KeyMap.DeleteWordForward.SetEnabled(true)
 	ta.KeyMap.DeleteAfterCursor.SetEnabled(true)
 	ta.KeyMap.DeleteBeforeCursor.SetEnabled(true)
+	themeTextArea(&ta)
+
+	keys, keysErr := NewKeyMap(cfg.Keys)
 
-	delegate := ui.NewItemDelegate(40)
+	delegate := ui.NewItemDelegate(40, Theme)
 	l := list.New([]list.Item{}, delegate, 40, 10)
 	l.Title = ""
 	l.SetShowStatusBar(false)
-	l.SetFilteringEnabled(false)
-	l.Styles.Title = TitleStyle
-	l.Styles.NoItems = NoItemsStyle
+	l.Filter = ui.Filter
+	l.FilterInput.Placeholder = "title, type:collection, date:18*"
+	themeListChrome(&l)
+
+	// The footer and help overlay show the list's keys, and only the quit
+	// binding quits
+	l.SetShowHelp(false)
+	l.KeyMap.Filter = keys.Filter
+	l.KeyMap.Quit.SetEnabled(false)
+	l.KeyMap.ForceQuit.SetEnabled(false)
+	l.KeyMap.ShowFullHelp.SetEnabled(false)
+	l.KeyMap.CloseFullHelp.SetEnabled(false)
 
 	s := spinner.New()
 	s.Spinner = spinner.Line
@@ -109,21 +279,77 @@ func InitialModel() *Model {
 	foundationModelsViewport := viewport.New(40, 10)
 	foundationModelsViewport.SetContent("Loading models...")
 
-	return &Model{
-		TextArea:        ta,
-		List:            l,
-		Status:          "Ready",
-		Spinner:         s,
-		Loading:         false,
-		InList:          false,
-		Width:           40,
-		ShowDetail:      false,
-		SelectedItem:    ui.Item{},
-		PrevItemsStack:  make([][]list.Item, 0),
-		ShowChat:        false,
-		Chat:            InitialChatModel(),
-		AvailableModels: []string{},
-		ModelViewport:   foundationModelsViewport,
-		Err:             nil,
+	// The chat service is created when the chat panel is first opened
+	ConfigureChat(cfg)
+
+	folderPrompt := textinput.New()
+	folderPrompt.Prompt = "📁 "
+	themeTextInput(&folderPrompt)
+
+	search := textinput.New()
+	search.Placeholder = "Describe what you are looking for..."
+	search.Prompt = "🔍 "
+	themeTextInput(&search)
+
+	chat := InitialChatModel()
+	chat.Inference = InferenceFromConfig(cfg.Chat.Inference)
+
+	contextBuilder := ContextBuilder{
+		Fields:      cfg.Context.Fields,
+		TokenBudget: cfg.Context.TokenBudget,
+	}
+
+	helpModel := help.New()
+	helpModel.Styles = helpStyles()
+
+	tabPrompt := textinput.New()
+	tabPrompt.Placeholder = "Tab name (empty to show the collection's label)"
+	themeTextInput(&tabPrompt)
+
+	m := &Model{
+		TextArea:         ta,
+		List:             l,
+		Status:           "Ready",
+		Spinner:          s,
+		Loading:          false,
+		InList:           false,
+		Width:            40,
+		ShowDetail:       false,
+		SelectedItem:     ui.Item{},
+		PrevItemsStack:   make([]NavLevel, 0),
+		History:          loadHistory(),
+		HistoryList:      newHistoryList(40, 10),
+		historyCursor:    -1,
+		ItemMetadata:     make(map[string]map[string]string),
+		Arrangements:     loadArrangements(),
+		ItemDetails:      make(map[string]itemDetails),
+		SplitWidth:       cfg.UI.SplitWidth,
+		SplitRatio:       cfg.UI.SplitRatio,
+		Previews:         newPreviewCache(),
+		ContextBuilder:   contextBuilder,
+		MaxToolSteps:     cfg.Chat.MaxToolSteps,
+		EmbeddingsConfig: cfg.Embeddings,
+		Search:           search,
+		FolderPrompt:     folderPrompt,
+		Tabs:             []*Tab{{}},
+		TabPrompt:        tabPrompt,
+		RestoreTabs:      cfg.UI.RestoreTabs,
+		Keys:             keys,
+		Mouse:            cfg.UI.Mouse,
+		Help:             helpModel,
+		ShowChat:         false,
+		Chat:             chat,
+		AvailableModels:  []string{},
+		ModelViewport:    foundationModelsViewport,
+		Err:              nil,
+	}
+	if m.RestoreTabs {
+		m.restoreTabs()
+	}
+	for _, err := range []error{themeErr, keysErr} {
+		if err != nil {
+			m.Status = err.Error()
+		}
 	}
+	return m
 }
@@ -0,0 +1,315 @@
+// File: /loam/internal/app/mouse.go
+
+package app
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// doubleClickTime is the longest time between the clicks of a double-click.
+const doubleClickTime = 400 * time.Millisecond
+
+// What a zone of the screen shows.
+const (
+	zoneInput     = "input"
+	zoneTab       = "tab"
+	zoneCrumb     = "crumb"
+	zoneList      = "list"
+	zonePreview   = "preview"
+	zoneDetail    = "detail"
+	zoneHistory   = "history"
+	zoneChat      = "chat"
+	zoneSessions  = "sessions"
+	zoneTemplates = "templates"
+)
+
+// zone is a part of the screen that responds to the mouse. Zones are
+// recorded each time the view is rendered.
+type zone struct {
+	ID         string
+	N          int // The tab or breadcrumb level
+	X, Y, W, H int
+
+	// Lines is the zone's text, searched for links under the pointer.
+	Lines []string
+}
+
+func (z zone) contains(x, y int) bool {
+	return x >= z.X && x < z.X+z.W && y >= z.Y && y < z.Y+z.H
+}
+
+// click is the last click, for telling a double-click.
+type click struct {
+	Zone  string
+	Index int
+	At    time.Time
+}
+
+// mark records a zone of what is being rendered, relative to its top left
+// corner; place moves it to where that ends up on the screen.
+func (m *Model) mark(z zone) {
+	m.zones = append(m.zones, z)
+}
+
+// place moves the zones marked since start by dx and dy.
+func (m *Model) place(start, dx, dy int) {
+	for i := start; i < len(m.zones); i++ {
+		m.zones[i].X += dx
+		m.zones[i].Y += dy
+	}
+}
+
+// textZone marks text rendered at x, y as a zone whose links can be clicked.
+func (m *Model) textZone(id string, x, y int, text string) {
+	lines := strings.Split(text, "\n")
+	m.mark(zone{ID: id, X: x, Y: y, W: lipgloss.Width(text), H: len(lines), Lines: lines})
+}
+
+// toggleMouse turns mouse capture on or off. While it is off, the terminal
+// selects text with the mouse as usual.
+func (m *Model) toggleMouse() tea.Cmd {
+	m.Mouse = !m.Mouse
+	if m.Mouse {
+		m.Status = "Mouse on: scroll and click. " + m.Keys.ToggleMouse.Help().Key + " to select text instead."
+		return tea.EnableMouseCellMotion
+	}
+	m.Status = "Mouse off: select text with the mouse. " + m.Keys.ToggleMouse.Help().Key + " to turn it back on."
+	return tea.DisableMouse
+}
+
+// updateMouse scrolls and clicks whatever is under the pointer.
+func (m *Model) updateMouse(msg tea.MouseMsg) tea.Cmd {
+	// Prompts and overlays without zones of their own take only keys
+	if m.ShowHelp || m.ShowTabPrompt || m.ShowSearch || m.ShowFolderPrompt || m.Enrichment != nil {
+		return nil
+	}
+	if msg.Action != tea.MouseActionPress {
+		return nil
+	}
+	var z zone
+	found := false
+	for _, candidate := range m.zones {
+		if candidate.contains(msg.X, msg.Y) {
+			z, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		m.scroll(z, msg)
+		return nil
+	case tea.MouseButtonLeft:
+		return m.clickZone(z, msg.X-z.X, msg.Y-z.Y)
+	}
+	return nil
+}
+
+// scroll moves the cursor of the list, or through the canvases or chat,
+// under the pointer.
+func (m *Model) scroll(z zone, msg tea.MouseMsg) {
+	up := msg.Button == tea.MouseButtonWheelUp
+	step := func(l *list.Model) {
+		if up {
+			l.CursorUp()
+		} else {
+			l.CursorDown()
+		}
+	}
+	switch z.ID {
+	case zoneList, zonePreview:
+		step(&m.List)
+		m.skipHeaders(up)
+	case zoneHistory:
+		step(&m.HistoryList)
+	case zoneSessions:
+		step(&m.Chat.Sessions)
+	case zoneTemplates:
+		step(&m.Chat.Templates)
+	case zoneChat:
+		m.Chat.Viewport, _ = m.Chat.Viewport.Update(msg)
+	case zoneDetail:
+		if res := m.DetailResource; res != nil && len(res.Canvases) > 0 {
+			if up {
+				m.CanvasIndex = (m.CanvasIndex - 1 + len(res.Canvases)) % len(res.Canvases)
+			} else {
+				m.CanvasIndex = (m.CanvasIndex + 1) % len(res.Canvases)
+			}
+		}
+	}
+}
+
+// clickZone handles a click at x, y within z.
+func (m *Model) clickZone(z zone, x, y int) tea.Cmd {
+	switch z.ID {
+	case zoneInput:
+		if m.InList {
+			m.InList = false
+			m.TextArea.Focus()
+			m.Status = "Ready"
+		}
+		return nil
+
+	case zoneTab:
+		return m.switchTab(z.N)
+
+	case zoneCrumb:
+		m.jumpTo(z.N)
+		return nil
+
+	case zoneList:
+		i, ok := listItemAt(&m.List, y, 2, 0)
+		if !ok {
+			return nil
+		}
+		if _, header := m.List.VisibleItems()[i].(ui.Header); header {
+			return nil
+		}
+		if !m.InList {
+			m.InList = true
+			m.TextArea.Blur()
+		}
+		m.List.Select(i)
+		if m.doubleClick(z.ID, i) {
+			return m.openSelected()
+		}
+		return nil
+
+	case zoneHistory, zoneSessions, zoneTemplates:
+		l := map[string]*list.Model{zoneHistory: &m.HistoryList, zoneSessions: &m.Chat.Sessions,
+			zoneTemplates: &m.Chat.Templates}[z.ID]
+		i, ok := listItemAt(l, y, 2, 1)
+		if !ok {
+			return nil
+		}
+		l.Select(i)
+		if !m.doubleClick(z.ID, i) {
+			return nil
+		}
+		// These overlays open their item on enter, whatever the bindings
+		enter := tea.KeyMsg{Type: tea.KeyEnter}
+		var cmd tea.Cmd
+		switch z.ID {
+		case zoneHistory:
+			_, cmd = m.updateHistory(enter)
+		case zoneSessions:
+			_, cmd = m.updateSessionBrowser(enter)
+		case zoneTemplates:
+			_, cmd = m.updateTemplatePalette(enter)
+		}
+		return cmd
+
+	case zoneDetail, zonePreview, zoneChat:
+		if y >= len(z.Lines) {
+			return nil
+		}
+		m.followLink(z.ID, z.Lines[y], x)
+	}
+	return nil
+}
+
+// doubleClick records a click on item i of a zone, reporting whether it
+// follows a click on the same item closely enough to be a double-click.
+func (m *Model) doubleClick(zoneID string, i int) bool {
+	now := time.Now()
+	last := m.lastClick
+	m.lastClick = click{Zone: zoneID, Index: i, At: now}
+	if last.Zone == zoneID && last.Index == i && now.Sub(last.At) <= doubleClickTime {
+		// A third click starts over
+		m.lastClick = click{}
+		return true
+	}
+	return false
+}
+
+// followLink opens the URL, or in the chat follows the citation, at column
+// x of a rendered line.
+func (m *Model) followLink(zoneID, line string, x int) {
+	word := wordAt(line, x, `[](){}<>"'`)
+	if strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") {
+		if err := iiif.OpenURL(word); err != nil {
+			m.Status = "Failed to open URL"
+		} else {
+			m.Status = "Opened in browser"
+		}
+		return
+	}
+	if zoneID != zoneChat {
+		return
+	}
+	// Citations are only separated by commas, e.g. [i3,r1]
+	id := wordAt(line, x, `[](){}<>"',`)
+	for i, ref := range m.Chat.Citations {
+		if ref.ID == id {
+			m.selectCitation(i)
+			m.followCitation()
+			return
+		}
+	}
+}
+
+// ansiPattern matches the escape sequences styles are rendered with.
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;:?]*[A-Za-z]")
+
+// wordAt returns the word at column x of a rendered line, where words are
+// separated by spaces and the given characters. Trailing punctuation is
+// left out.
+func wordAt(line string, x int, breaks string) string {
+	runes := []rune(ansiPattern.ReplaceAllString(line, ""))
+	isBreak := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(breaks, r)
+	}
+
+	at, col := -1, 0
+	for i, r := range runes {
+		w := lipgloss.Width(string(r))
+		if x >= col && x < col+w {
+			at = i
+			break
+		}
+		col += w
+	}
+	if at < 0 || isBreak(runes[at]) {
+		return ""
+	}
+	start, end := at, at+1
+	for start > 0 && !isBreak(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && !isBreak(runes[end]) {
+		end++
+	}
+	return strings.TrimRight(string(runes[start:end]), ".,;:!?")
+}
+
+// listItemAt returns the index among the visible items of the item on row
+// y of a list's view, for a delegate drawing items height rows tall and
+// spacing rows apart.
+func listItemAt(l *list.Model, y, height, spacing int) (int, bool) {
+	if l.ShowTitle() || (l.ShowFilter() && l.FilteringEnabled()) {
+		y -= lipgloss.Height(l.Styles.TitleBar.Render(""))
+	}
+	if l.ShowStatusBar() {
+		y -= lipgloss.Height(l.Styles.StatusBar.Render(""))
+	}
+	if y < 0 || y%(height+spacing) >= height {
+		return 0, false
+	}
+	i := l.Paginator.Page*l.Paginator.PerPage + y/(height+spacing)
+	if i >= min(len(l.VisibleItems()), (l.Paginator.Page+1)*l.Paginator.PerPage) {
+		return 0, false
+	}
+	return i, true
+}
@@ -0,0 +1,139 @@
+// File: /loam/internal/app/nav.go
+
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// NavLevel is one list in the path from the root collection to the list
+// being shown: a collection, or the results of a semantic search.
+type NavLevel struct {
+	Label    string
+	URL      string // Empty for search results
+	Items    []list.Item
+	Resource *iiif.Resource
+
+	// Index is the selected item. Selecting it again also restores the
+	// page of the list that was scrolled to.
+	Index int
+}
+
+// currentLevel captures the list being shown so it can be returned to.
+func (m *Model) currentLevel() NavLevel {
+	return NavLevel{
+		Label:    m.Level.Label,
+		URL:      m.Level.URL,
+		Items:    m.List.Items(),
+		Resource: m.Resource,
+		Index:    m.selectedIndex(),
+	}
+}
+
+// selectedIndex is the position of the selected item among all of the
+// list's items, even while a filter hides some of them.
+func (m *Model) selectedIndex() int {
+	if m.List.FilterState() == list.Unfiltered {
+		return m.List.Index()
+	}
+	selected, ok := m.List.SelectedItem().(ui.Item)
+	if !ok {
+		return 0
+	}
+	for i, item := range m.List.Items() {
+		if it, ok := item.(ui.Item); ok && it.URL == selected.URL {
+			return i
+		}
+	}
+	return 0
+}
+
+// setLevel names the list being shown after fetching res, pushing the list
+// it was reached from, if any, onto the navigation stack. Loading a URL typed
+// into the input starts a new path.
+func (m *Model) setLevel(res *iiif.Resource) {
+	if m.pendingLevel != nil {
+		m.PrevItemsStack = append(m.PrevItemsStack, *m.pendingLevel)
+		m.pendingLevel = nil
+	} else {
+		m.PrevItemsStack = m.PrevItemsStack[:0]
+	}
+	m.Level = NavLevel{Label: res.Label, URL: res.ID}
+	if m.Level.Label == "" {
+		m.Level.Label = res.ID
+	}
+}
+
+// jumpTo returns to level n of the breadcrumb, where 0 is the root, restoring
+// its items, selection and page. Deeper levels are dropped.
+func (m *Model) jumpTo(n int) {
+	if n < 0 || n >= len(m.PrevItemsStack) {
+		return
+	}
+	level := m.PrevItemsStack[n]
+	m.PrevItemsStack = m.PrevItemsStack[:n]
+	m.restoreLevel(level)
+	m.Status = "Went back to " + level.Label
+}
+
+// restoreLevel shows a level captured by currentLevel.
+func (m *Model) restoreLevel(level NavLevel) {
+	m.List.ResetFilter()
+	m.List.SetItems(level.Items)
+	m.List.Select(level.Index)
+	m.Resource = level.Resource
+	m.Level = NavLevel{Label: level.Label, URL: level.URL}
+	m.refreshChatContext()
+
+	// Bookmarks may have changed since the level was left
+	if folder, ok := m.bookmarksLevel(); ok {
+		m.reloadBookmarksLevel(folder)
+	}
+}
+
+// breadcrumbView renders the path to the current list, numbering ancestors
+// with the key that jumps to them, and marks the ancestors as zones to click.
+// Levels are elided from the left when the path is wider than the screen.
+func (m *Model) breadcrumbView() string {
+	if m.Level.Label == "" {
+		return ""
+	}
+	var crumbs []string
+	for i, level := range m.PrevItemsStack {
+		label := truncateLabel(level.Label, 30)
+		if i < 9 {
+			label = fmt.Sprintf("%d %s", i+1, label)
+		}
+		crumbs = append(crumbs, BreadcrumbStyle.Render(label))
+	}
+	crumbs = append(crumbs, CurrentCrumbStyle.Render(truncateLabel(m.Level.Label, 40)))
+
+	sep := BreadcrumbStyle.Render(" › ")
+	path := strings.Join(crumbs, sep)
+	first, x := 0, 0
+	for len(crumbs) > 1 && m.Width > 0 && lipgloss.Width(path) > m.Width {
+		crumbs = crumbs[1:]
+		first++
+		path = BreadcrumbStyle.Render("…") + sep + strings.Join(crumbs, sep)
+		x = lipgloss.Width(BreadcrumbStyle.Render("…") + sep)
+	}
+	for i, crumb := range crumbs[:len(crumbs)-1] {
+		m.mark(zone{ID: zoneCrumb, N: first + i, X: x, W: lipgloss.Width(crumb), H: 1})
+		x += lipgloss.Width(crumb + sep)
+	}
+	return path
+}
+
+func truncateLabel(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
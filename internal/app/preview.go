@@ -0,0 +1,302 @@
+// File: /loam/internal/app/preview.go
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewDelay is how long the highlight must rest on an item before its
+// preview is fetched, so scrolling through the list doesn't fetch every item.
+const previewDelay = 200 * time.Millisecond
+
+// thumbnailEdge is the largest edge, in pixels, of thumbnails fetched from an
+// Image API service.
+const thumbnailEdge = 256
+
+// previewCacheSize is how many previews are kept. The least recently shown
+// are dropped first.
+const previewCacheSize = 32
+
+// previewImageEdge is the largest edge, in pixels, of the images kept with
+// previews. A preview column is rarely wider than a hundred cells, so larger
+// images would only take memory.
+const previewImageEdge = 128
+
+// Preview is what the split layout shows about a list item once it has
+// been fetched.
+type Preview struct {
+	Resource *iiif.Resource
+	Image    image.Image // Thumbnail, if the resource has one, downscaled
+	Err      error
+
+	thumb      string // Image rendered at thumbWidth
+	thumbWidth int
+}
+
+// previewCache holds the most recently used previews by URL.
+type previewCache struct {
+	entries map[string]*Preview
+	order   []string // Least recently used first
+}
+
+func newPreviewCache() *previewCache {
+	return &previewCache{entries: make(map[string]*Preview)}
+}
+
+// Get returns the preview of url, or nil, marking it as recently used.
+func (c *previewCache) Get(url string) *Preview {
+	p := c.entries[url]
+	if p != nil {
+		c.touch(url)
+	}
+	return p
+}
+
+// Put stores the preview of url, dropping the least recently used preview
+// if the cache is full.
+func (c *previewCache) Put(url string, p *Preview) {
+	if _, ok := c.entries[url]; !ok && len(c.order) >= previewCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[url] = p
+	c.touch(url)
+}
+
+// Remove drops the preview of url.
+func (c *previewCache) Remove(url string) {
+	delete(c.entries, url)
+	c.order = removeString(c.order, url)
+}
+
+// touch moves url to the most recently used end.
+func (c *previewCache) touch(url string) {
+	c.order = append(removeString(c.order, url), url)
+}
+
+func removeString(list []string, s string) []string {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// previewTickMsg fires previewDelay after the highlight moved to URL.
+type previewTickMsg struct {
+	URL string
+}
+
+// PreviewMsg carries a fetched preview.
+type PreviewMsg struct {
+	URL     string
+	Preview *Preview
+}
+
+// FetchPreview fetches a collection or manifest and its thumbnail. A missing
+// or unreadable thumbnail is not an error.
+func FetchPreview(url string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := iiif.FetchDataSync(url)
+		if err != nil {
+			return PreviewMsg{URL: url, Preview: &Preview{Err: err}}
+		}
+		res, err := iiif.ParseResource(data)
+		if err != nil {
+			return PreviewMsg{URL: url, Preview: &Preview{Err: err}}
+		}
+		p := &Preview{Resource: res}
+
+		thumbURL := res.Thumbnail
+		if thumbURL == "" && len(res.Canvases) > 0 {
+			c := res.Canvases[0]
+			thumbURL = c.ImageURL
+			if c.ImageService != "" {
+				thumbURL = iiif.ImageAPIURL(c.ImageService, iiif.ImageRequest{
+					Size: fmt.Sprintf("!%d,%d", thumbnailEdge, thumbnailEdge),
+				})
+			}
+		}
+		if thumbURL != "" {
+			if img, _, err := iiif.FetchImageSync(thumbURL, maxImageBytes); err == nil {
+				if decoded, _, err := image.Decode(bytes.NewReader(img)); err == nil {
+					p.Image = downscale(decoded, previewImageEdge)
+				}
+			}
+		}
+		return PreviewMsg{URL: url, Preview: p}
+	}
+}
+
+// downscale returns img shrunk to fit in edge x edge pixels, each pixel the
+// average of the pixels it covers. Smaller images are returned as they are.
+func downscale(img image.Image, edge int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= edge && b.Dy() <= edge {
+		return img
+	}
+	w, h := edge, edge*b.Dy()/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = edge*b.Dx()/b.Dy(), edge
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var r, g, bl, a, n uint64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					cr, cg, cb, ca := img.At(px, py).RGBA()
+					r, g, bl, a, n = r+uint64(cr>>8), g+uint64(cg>>8), bl+uint64(cb>>8), a+uint64(ca>>8), n+1
+				}
+			}
+			out.Set(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return out
+}
+
+// layoutSplit decides from the terminal width whether the list and preview
+// are shown side by side, returning the width of the list's column.
+func (m *Model) layoutSplit(contentWidth int) int {
+	m.Split = m.SplitWidth > 0 && contentWidth+4 >= m.SplitWidth
+	if !m.Split {
+		m.PreviewWidth = 0
+		return contentWidth
+	}
+	ratio := m.SplitRatio
+	if ratio < 0.2 || ratio > 0.8 {
+		ratio = 0.5
+	}
+	listWidth := int(float64(contentWidth) * ratio)
+	m.PreviewWidth = contentWidth - listWidth - 1
+	return listWidth
+}
+
+// schedulePreview starts the delay before fetching the preview of a newly
+// highlighted item.
+func (m *Model) schedulePreview() tea.Cmd {
+	if !m.Split || m.ShowDetail {
+		return nil
+	}
+	item, ok := m.List.SelectedItem().(ui.Item)
+	if !ok || item.URL == m.previewURL {
+		return nil
+	}
+	m.previewURL = item.URL
+	m.previewErr = nil
+	if !previewable(item) || m.Previews.Get(item.URL) != nil {
+		return nil
+	}
+	url := item.URL
+	return tea.Tick(previewDelay, func(time.Time) tea.Msg {
+		return previewTickMsg{URL: url}
+	})
+}
+
+// previewable reports whether an item is a collection or manifest that can
+// be fetched for its preview.
+func previewable(item ui.Item) bool {
+	return strings.EqualFold(item.ItemType, "collection") || strings.EqualFold(item.ItemType, "manifest")
+}
+
+// updatePreview handles the preview delay and fetched previews.
+func (m *Model) updatePreview(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case previewTickMsg:
+		// Fetch only if the highlight is still on the item
+		if msg.URL != m.previewURL || m.Previews.Get(msg.URL) != nil {
+			return nil
+		}
+		m.Previews.Put(msg.URL, &Preview{})
+		return FetchPreview(msg.URL)
+
+	case PreviewMsg:
+		// Failures aren't cached, so the preview is fetched again the next
+		// time the item is highlighted
+		if msg.Preview.Err != nil {
+			m.Previews.Remove(msg.URL)
+			if msg.URL == m.previewURL {
+				m.previewErr = msg.Preview.Err
+			}
+			return nil
+		}
+		m.Previews.Put(msg.URL, msg.Preview)
+		if res := msg.Preview.Resource; res != nil {
+			m.cacheMetadata(res)
+		}
+	}
+	return nil
+}
+
+// previewView renders the highlighted item's preview in a column width
+// cells wide and height lines high.
+func (m *Model) previewView(width, height int) string {
+	item, ok := m.List.SelectedItem().(ui.Item)
+	if !ok {
+		return ""
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+
+	lines := []string{TitleStyle.Render(wrap.Render(item.Title)), HelpStyle.Render(item.ItemType), wrap.Render(item.URL)}
+	p := m.Previews.Get(item.URL)
+	switch {
+	case !previewable(item):
+	case p == nil && m.previewErr != nil && item.URL == m.previewURL:
+		lines = append(lines, "", "Preview failed: "+m.previewErr.Error())
+	case p == nil || p.Resource == nil:
+		lines = append(lines, "", HelpStyle.Render("Loading preview..."))
+	default:
+		res := p.Resource
+		if p.Image != nil && !Theme.NoColor {
+			if p.thumbWidth != width {
+				p.thumb = ui.Thumbnail(p.Image, width, height/2)
+				p.thumbWidth = width
+			}
+			lines = append(lines, "", p.thumb)
+		}
+		switch res.Type {
+		case "Manifest":
+			lines = append(lines, "", fmt.Sprintf("Canvases: %d", len(res.Canvases)))
+		case "Collection":
+			lines = append(lines, "", fmt.Sprintf("Items: %d", len(res.Items)))
+		}
+		if res.NavDate != "" {
+			lines = append(lines, "Date: "+res.NavDate)
+		}
+		if res.Summary != "" {
+			lines = append(lines, "", wrap.Render(res.Summary))
+		}
+		if len(res.Metadata) > 0 {
+			lines = append(lines, "")
+			for _, e := range res.Metadata {
+				lines = append(lines, wrap.Render(HelpStyle.Render(e.Label+": ")+e.Value))
+			}
+		}
+	}
+
+	// Cut the preview to the height of the list
+	out := strings.Split(lipgloss.JoinVertical(lipgloss.Left, lines...), "\n")
+	if len(out) > height {
+		out = out[:height]
+	}
+	return strings.Join(out, "\n")
+}
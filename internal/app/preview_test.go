@@ -0,0 +1,58 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+)
+
+func TestPreviewCacheDropsLeastRecentlyUsed(t *testing.T) {
+	c := newPreviewCache()
+	for i := 0; i < previewCacheSize; i++ {
+		c.Put(fmt.Sprint(i), &Preview{})
+	}
+	c.Get("0")
+	c.Put("new", &Preview{})
+
+	if c.Get("1") != nil {
+		t.Error("least recently used preview was kept")
+	}
+	if c.Get("0") == nil || c.Get("new") == nil {
+		t.Error("recently used preview was dropped")
+	}
+	if len(c.entries) != previewCacheSize {
+		t.Errorf("%d previews cached, want %d", len(c.entries), previewCacheSize)
+	}
+}
+
+func TestFailedPreviewIsNotCached(t *testing.T) {
+	m := chatModel(t, 0)
+	m.previewURL = "https://example.org/m"
+	m.updatePreview(previewTickMsg{URL: m.previewURL})
+	m.updatePreview(PreviewMsg{URL: m.previewURL, Preview: &Preview{Err: errors.New("timeout")}})
+
+	if m.Previews.Get(m.previewURL) != nil {
+		t.Error("failed preview was cached")
+	}
+	if m.previewErr == nil {
+		t.Error("failure of the highlighted item's preview was not kept to show")
+	}
+	if cmd := m.updatePreview(previewTickMsg{URL: m.previewURL}); cmd == nil {
+		t.Error("failed preview was not fetched again")
+	}
+
+	m.updatePreview(PreviewMsg{URL: m.previewURL, Preview: &Preview{Resource: &iiif.Resource{}}})
+	if m.Previews.Get(m.previewURL) == nil {
+		t.Error("fetched preview was not cached")
+	}
+}
+
+func TestDownscaleKeepsAspect(t *testing.T) {
+	img := downscale(image.NewRGBA(image.Rect(0, 0, 1000, 500)), previewImageEdge)
+	if b := img.Bounds(); b.Dx() != previewImageEdge || b.Dy() != previewImageEdge/2 {
+		t.Errorf("downscaled to %dx%d", b.Dx(), b.Dy())
+	}
+}
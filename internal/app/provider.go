@@ -0,0 +1,226 @@
+// File: /loam/internal/app/provider.go
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Chat provider names, as used in the config file, --provider and LOAM_CHAT_PROVIDER.
+const (
+	ProviderBedrock = "bedrock"
+	ProviderMock    = "mock"
+)
+
+// ChatProvider sends a chat request to a model and returns its reply.
+type ChatProvider interface {
+	// Converse returns the model's next message. If onChunk is non-nil the
+	// reply text is also delivered incrementally as it is generated.
+	Converse(ctx context.Context, req ChatRequest, onChunk func(text string)) (*ChatResponse, error)
+
+	// ModelID identifies the model replies come from.
+	ModelID() string
+
+	// Models lists the models the provider can use.
+	Models(ctx context.Context) ([]string, error)
+}
+
+// bedrockModelPrefix is the model family whose native request format
+// BedrockProvider sends.
+const bedrockModelPrefix = "amazon.nova-"
+
+// errUnsupportedModel is returned for models BedrockProvider can't talk to.
+var errUnsupportedModel = errors.New("model is not supported")
+
+// CheckBedrockModel reports an error for models that don't accept the
+// Amazon Nova request format, such as Anthropic or Meta models, which
+// Bedrock would otherwise reject on every request.
+func CheckBedrockModel(modelID string) error {
+	if !strings.HasPrefix(baseModelID(modelID), bedrockModelPrefix) {
+		return fmt.Errorf("%w: %q (only Amazon Nova models, %s*, and their inference profiles can be used)",
+			errUnsupportedModel, modelID, bedrockModelPrefix)
+	}
+	return nil
+}
+
+// BedrockProvider talks to a model through the AWS Bedrock Runtime.
+type BedrockProvider struct {
+	Client      *bedrockruntime.Client
+	ModelClient *bedrock.Client
+	Model       string
+	Region      string
+}
+
+// ModelID returns the Bedrock model id.
+func (p *BedrockProvider) ModelID() string { return p.Model }
+
+// Converse invokes the model, streaming the response when onChunk is set.
+func (p *BedrockProvider) Converse(ctx context.Context, req ChatRequest, onChunk func(string)) (*ChatResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %w", err)
+	}
+
+	var response *ChatResponse
+	if onChunk == nil {
+		response, err = p.invoke(ctx, body)
+	} else {
+		response, err = p.invokeStream(ctx, body, onChunk)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Output.Message.Content) == 0 {
+		return nil, fmt.Errorf("no assistant message found in the response")
+	}
+	return response, nil
+}
+
+func (p *BedrockProvider) invoke(ctx context.Context, body []byte) (*ChatResponse, error) {
+	output, err := p.Client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(p.Model),
+		ContentType: aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke model: %w", err)
+	}
+
+	var response ChatResponse
+	if err := json.Unmarshal(output.Body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+// streamEvent is one chunk of a streamed response. Exactly one field is set.
+type streamEvent struct {
+	ContentBlockStart *struct {
+		ContentBlockIndex int `json:"contentBlockIndex"`
+		Start             struct {
+			ToolUse *struct {
+				ToolUseID string `json:"toolUseId"`
+				Name      string `json:"name"`
+			} `json:"toolUse"`
+		} `json:"start"`
+	} `json:"contentBlockStart"`
+	ContentBlockDelta *struct {
+		ContentBlockIndex int `json:"contentBlockIndex"`
+		Delta             struct {
+			Text    string `json:"text"`
+			ToolUse *struct {
+				Input string `json:"input"`
+			} `json:"toolUse"`
+		} `json:"delta"`
+	} `json:"contentBlockDelta"`
+	MessageStop *struct {
+		StopReason string `json:"stopReason"`
+	} `json:"messageStop"`
+	Metadata *struct {
+		Usage *Usage `json:"usage"`
+	} `json:"metadata"`
+}
+
+// streamBlock accumulates one content block of a streamed response.
+type streamBlock struct {
+	text    strings.Builder
+	toolUse *ToolUse
+	input   strings.Builder
+}
+
+func (p *BedrockProvider) invokeStream(ctx context.Context, body []byte, onChunk func(string)) (*ChatResponse, error) {
+	output, err := p.Client.InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(p.Model),
+		ContentType: aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke model: %w", err)
+	}
+	stream := output.GetStream()
+	defer stream.Close()
+
+	var (
+		blocks     []*streamBlock
+		stopReason string
+		usage      *Usage
+	)
+	block := func(i int) *streamBlock {
+		for len(blocks) <= i {
+			blocks = append(blocks, &streamBlock{})
+		}
+		return blocks[i]
+	}
+
+	for event := range stream.Events() {
+		chunk, ok := event.(*types.ResponseStreamMemberChunk)
+		if !ok {
+			continue
+		}
+		var ev streamEvent
+		if err := json.Unmarshal(chunk.Value.Bytes, &ev); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response chunk: %w", err)
+		}
+		switch {
+		case ev.ContentBlockStart != nil:
+			if use := ev.ContentBlockStart.Start.ToolUse; use != nil {
+				block(ev.ContentBlockStart.ContentBlockIndex).toolUse = &ToolUse{ToolUseID: use.ToolUseID, Name: use.Name}
+			}
+		case ev.ContentBlockDelta != nil:
+			b := block(ev.ContentBlockDelta.ContentBlockIndex)
+			if use := ev.ContentBlockDelta.Delta.ToolUse; use != nil {
+				b.input.WriteString(use.Input)
+			} else if text := ev.ContentBlockDelta.Delta.Text; text != "" {
+				b.text.WriteString(text)
+				onChunk(text)
+			}
+		case ev.MessageStop != nil:
+			stopReason = ev.MessageStop.StopReason
+		case ev.Metadata != nil:
+			usage = ev.Metadata.Usage
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response stream: %w", err)
+	}
+
+	response := &ChatResponse{StopReason: stopReason, Usage: usage}
+	response.Output.Message.Role = "assistant"
+	for _, b := range blocks {
+		switch {
+		case b.toolUse != nil:
+			input := strings.TrimSpace(b.input.String())
+			if input == "" {
+				input = "{}"
+			}
+			b.toolUse.Input = json.RawMessage(input)
+			response.Output.Message.Content = append(response.Output.Message.Content, ContentBlock{ToolUse: b.toolUse})
+		case b.text.Len() > 0:
+			response.Output.Message.Content = append(response.Output.Message.Content, ContentBlock{Text: b.text.String()})
+		}
+	}
+	return response, nil
+}
+
+// Models lists the foundation models available to the account.
+func (p *BedrockProvider) Models(ctx context.Context) ([]string, error) {
+	output, err := p.ModelClient.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, err
+	}
+	modelIDs := make([]string, len(output.ModelSummaries))
+	for i, model := range output.ModelSummaries {
+		modelIDs[i] = *model.ModelId
+	}
+	return modelIDs, nil
+}
@@ -0,0 +1,319 @@
+// File: /loam/internal/app/schema.go
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// OutputSchema is a named JSON schema that structured answers must match.
+type OutputSchema struct {
+	Name   string
+	Schema map[string]interface{}
+}
+
+// builtinSchemas are always available; files in the schemas directory with
+// the same name replace them.
+var builtinSchemas = map[string]string{
+	"metadata-suggestions": `{
+  "type": "object",
+  "properties": {
+    "suggestions": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "label": {"type": "string", "description": "Metadata field, e.g. Date, Subject or Creator"},
+          "value": {"type": "string"},
+          "rationale": {"type": "string", "description": "Which parts of the resource support the value"}
+        },
+        "required": ["label", "value"]
+      }
+    }
+  },
+  "required": ["suggestions"]
+}`,
+	"transcription": `{
+  "type": "object",
+  "properties": {
+    "text": {"type": "string", "description": "The transcribed text, with line breaks preserved"},
+    "language": {"type": "string", "description": "BCP 47 language tag"},
+    "confidence": {"type": "string", "enum": ["high", "medium", "low"]},
+    "notes": {"type": "string", "description": "Illegible passages or other caveats"}
+  },
+  "required": ["text"]
+}`,
+}
+
+// LoadSchema returns the schema in the file at nameOrPath, or else the named
+// schema from the schemas directory in the config dir or the built-ins.
+func LoadSchema(nameOrPath string) (OutputSchema, error) {
+	path := nameOrPath
+	if _, err := os.Stat(path); err != nil {
+		dir, err := config.Path("schemas")
+		if err != nil {
+			return OutputSchema{}, err
+		}
+		path = filepath.Join(dir, nameOrPath+".json")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(nameOrPath), ".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		text, ok := builtinSchemas[nameOrPath]
+		if !ok {
+			return OutputSchema{}, fmt.Errorf("no schema named %q (built-in schemas: %s)", nameOrPath, strings.Join(BuiltinSchemaNames(), ", "))
+		}
+		data = []byte(text)
+	} else if err != nil {
+		return OutputSchema{}, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	schema := OutputSchema{Name: name}
+	if err := json.Unmarshal(data, &schema.Schema); err != nil {
+		return OutputSchema{}, fmt.Errorf("failed to parse schema %s: %w", name, err)
+	}
+	return schema, nil
+}
+
+// BuiltinSchemaNames lists the built-in schemas, sorted by name.
+func BuiltinSchemaNames() []string {
+	names := make([]string, 0, len(builtinSchemas))
+	for name := range builtinSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Instructions tells the model to answer with JSON matching the schema.
+func (s OutputSchema) Instructions() string {
+	schema, _ := json.MarshalIndent(s.Schema, "", "  ")
+	return "Respond with only a JSON value, without any other text or code fences, that conforms to this JSON schema:\n\n" + string(schema)
+}
+
+// Validate checks a JSON document against the schema and returns a
+// description of each violation.
+//
+// Only a subset of JSON Schema is supported: type, enum, properties,
+// required, additionalProperties, items, minItems, maxItems, minLength,
+// maxLength, minimum and maximum.
+func (s OutputSchema) Validate(data []byte) []string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("not valid JSON: %v", err)}
+	}
+	var problems []string
+	validateValue(s.Schema, value, "$", &problems)
+	return problems
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		fail("expected %s, got %s", typeNames(t), jsonType(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) && jsonType(e) == jsonType(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, present := v[name]; !present {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := props[k].(map[string]interface{}); ok {
+				validateValue(sub, v[k], path+"."+k, problems)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("unexpected property %q", k)
+				}
+			case map[string]interface{}:
+				validateValue(extra, v[k], path+"."+k, problems)
+			}
+		}
+
+	case []interface{}:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			fail("must have at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+
+	case string:
+		length := float64(len([]rune(v)))
+		if n, ok := number(schema["minLength"]); ok && length < n {
+			fail("must be at least %v characters", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && length > n {
+			fail("must be at most %v characters", n)
+		}
+
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && v < n {
+			fail("must be at least %v", n)
+		}
+		if n, ok := number(schema["maximum"]); ok && v > n {
+			fail("must be at most %v", n)
+		}
+	}
+}
+
+// matchesType reports whether value has the schema type t, which may be a
+// single type name or a list of them.
+func matchesType(t interface{}, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonType(value)
+		if t == "number" && actual == "integer" {
+			return true
+		}
+		return t == actual
+	case []interface{}:
+		for _, name := range t {
+			if matchesType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func typeNames(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, len(list))
+		for i, name := range list {
+			names[i] = fmt.Sprint(name)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+// codeFencePattern matches a reply wrapped in a Markdown code block.
+var codeFencePattern = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*?)\\s*```$")
+
+// extractJSON pulls the JSON value out of a reply, tolerating code fences and
+// text around the value.
+func extractJSON(reply string) []byte {
+	reply = strings.TrimSpace(reply)
+	if m := codeFencePattern.FindStringSubmatch(reply); m != nil {
+		reply = m[1]
+	}
+	if json.Valid([]byte(reply)) {
+		return []byte(reply)
+	}
+	start := strings.IndexAny(reply, "{[")
+	end := strings.LastIndexAny(reply, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(reply[start:end+1])) {
+		return []byte(reply[start : end+1])
+	}
+	return []byte(reply)
+}
+
+// SendStructuredSync sends a prompt asking for an answer that conforms to
+// schema and returns the validated JSON. Answers that do not conform are sent
+// back to the model with the problems found, up to retries times.
+func (cs *ChatService) SendStructuredSync(prompt, chatContext string, schema OutputSchema, retries int, onTool func(use ToolUse, result string)) (json.RawMessage, error) {
+	ctx := context.Background()
+	history := []Message{{
+		Role:    "user",
+		Content: []ContentBlock{{Text: prompt + "\n\n" + schema.Instructions()}},
+	}}
+
+	for attempt := 0; ; attempt++ {
+		var err error
+		history, err = cs.converseSync(ctx, history, chatContext, onTool)
+		if err != nil {
+			return nil, err
+		}
+		answer := extractJSON(history[len(history)-1].Text())
+		problems := schema.Validate(answer)
+		if len(problems) == 0 {
+			return json.RawMessage(answer), nil
+		}
+		if attempt >= retries {
+			return nil, fmt.Errorf("answer does not match the %s schema after %d attempts:\n%s", schema.Name, attempt+1, strings.Join(problems, "\n"))
+		}
+
+		history = append(history, Message{
+			Role: "user",
+			Content: []ContentBlock{{Text: "That answer does not conform to the schema:\n- " +
+				strings.Join(problems, "\n- ") + "\n\nRespond again with only the corrected JSON."}},
+		})
+	}
+}
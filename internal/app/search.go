@@ -0,0 +1,184 @@
+// File: /loam/internal/app/search.go
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/index"
+	"github.com/bmquinn/loam-iiif/internal/types"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Default embedding models for each provider.
+const (
+	defaultBedrockEmbeddingModel = "amazon.titan-embed-text-v2:0"
+	defaultOpenAIEmbeddingModel  = "text-embedding-3-small"
+)
+
+// SemanticSearchMsg carries the results of a semantic search box query.
+type SemanticSearchMsg struct {
+	Query string
+	Hits  []index.Hit
+}
+
+// ChatRetrievalMsg carries the manifests retrieved for a chat prompt. Err is
+// set when retrieval failed; the prompt is then sent without them.
+type ChatRetrievalMsg struct {
+	Hits []index.Hit
+	Err  error
+}
+
+// NewEmbedder creates the embedder described by cfg.Embeddings, recording
+// its requests in the usage log unless usage is disabled. Bedrock embedders
+// get an AWS client of their own from cfg.Bedrock, whichever chat provider
+// is in use.
+func NewEmbedder(lcfg config.Config) (index.Embedder, error) {
+	cfg := lcfg.Embeddings
+	switch cfg.Provider {
+	case index.ProviderBedrock, "":
+		client, err := NewBedrockClient(lcfg.Bedrock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
+		}
+		model := cfg.Model
+		if model == "" {
+			model = defaultBedrockEmbeddingModel
+		}
+		return &index.BedrockEmbedder{
+			Client:     client,
+			ModelID:    model,
+			Dimensions: cfg.Dimensions,
+			OnRequest:  embeddingUsage(lcfg, model),
+		}, nil
+
+	case index.ProviderOpenAI:
+		model := cfg.Model
+		if model == "" {
+			model = defaultOpenAIEmbeddingModel
+		}
+		return &index.OpenAIEmbedder{
+			Endpoint:   cfg.Endpoint,
+			APIKey:     os.Getenv(cfg.APIKeyEnv),
+			ModelID:    model,
+			Dimensions: cfg.Dimensions,
+			OnRequest:  embeddingUsage(lcfg, model),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown embeddings provider %q", cfg.Provider)
+}
+
+// SearchIndex embeds query with the same provider, model and dimensions the
+// index was built with, so the vectors are comparable, and returns the
+// closest cfg.TopK entries.
+func SearchIndex(ix *index.Index, cfg config.Config, query string) ([]index.Hit, error) {
+	cfg.Embeddings.Provider = ix.Provider
+	cfg.Embeddings.Model = ix.Model
+	cfg.Embeddings.Dimensions = ix.Dimensions
+	embedder, err := NewEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := embedder.Embed(context.Background(), []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return ix.Search(vectors[0], cfg.Embeddings.TopK), nil
+}
+
+// SemanticSearch creates a command that queries the index for the search box.
+func SemanticSearch(ix *index.Index, cfg config.EmbeddingsConfig, query string) tea.Cmd {
+	return func() tea.Msg {
+		hits, err := SearchIndex(ix, embeddingsConfig(cfg), query)
+		if err != nil {
+			return types.ErrMsg{Error: err}
+		}
+		return SemanticSearchMsg{Query: query, Hits: hits}
+	}
+}
+
+// RetrieveForChat creates a command that finds manifests relevant to a chat prompt.
+func RetrieveForChat(ix *index.Index, cfg config.EmbeddingsConfig, prompt string) tea.Cmd {
+	return func() tea.Msg {
+		hits, err := SearchIndex(ix, embeddingsConfig(cfg), prompt)
+		return ChatRetrievalMsg{Hits: hits, Err: err}
+	}
+}
+
+// loadIndex picks up the semantic index for a freshly fetched collection.
+// Nested collections keep using the index of the collection that was crawled.
+func (m *Model) loadIndex(collectionURL string) {
+	ix, err := index.Load(collectionURL)
+	if err != nil {
+		return
+	}
+	m.Index = ix
+	for _, e := range ix.Entries {
+		if e.Metadata != nil {
+			m.ItemMetadata[e.ID] = e.Metadata
+		}
+	}
+	m.applyMetadata()
+}
+
+// openSearch shows the semantic search box if the collection is indexed.
+func (m *Model) openSearch() tea.Cmd {
+	if m.Index == nil {
+		m.Status = "No semantic index for this collection. Run `loam-iiif index <url>` first."
+		return nil
+	}
+	m.ShowSearch = true
+	m.Search.Reset()
+	m.Status = fmt.Sprintf("Semantic search over %d manifests", len(m.Index.Entries))
+	return m.Search.Focus()
+}
+
+// updateSearch handles keys while the semantic search box is open.
+func (m *Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.ShowSearch = false
+		m.Search.Blur()
+		m.Status = "Closed semantic search."
+		return m, nil
+	case "enter":
+		query := strings.TrimSpace(m.Search.Value())
+		if query == "" {
+			return m, nil
+		}
+		m.ShowSearch = false
+		m.Search.Blur()
+		m.Status = fmt.Sprintf("Searching for %q...", query)
+		m.Loading = true
+		return m, tea.Batch(SemanticSearch(m.Index, m.EmbeddingsConfig, query), m.Spinner.Tick)
+	}
+
+	var cmd tea.Cmd
+	m.Search, cmd = m.Search.Update(msg)
+	return m, cmd
+}
+
+// showSearchResults replaces the list with search hits; Esc goes back.
+func (m *Model) showSearchResults(msg SemanticSearchMsg) {
+	m.Loading = false
+	m.PrevItemsStack = append(m.PrevItemsStack, m.currentLevel())
+	m.Level = NavLevel{Label: fmt.Sprintf("Search: %q", msg.Query)}
+
+	items := make([]list.Item, 0, len(msg.Hits))
+	for _, hit := range msg.Hits {
+		items = append(items, ui.Item{URL: hit.ID, Title: hit.Label, ItemType: hit.Type, Metadata: m.ItemMetadata[hit.ID],
+			Order: len(items)})
+	}
+	m.List.SetItems(m.arrangeItems(m.Level.URL, items))
+	m.List.Select(0)
+	m.skipHeaders(false)
+	m.Status = fmt.Sprintf("%d semantic matches for %q (Esc to go back)", len(items), msg.Query)
+}
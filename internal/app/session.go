@@ -0,0 +1,242 @@
+// File: /loam/internal/app/session.go
+
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// ChatSession is a saved conversation about a IIIF resource.
+type ChatSession struct {
+	ID            string `json:"id"`
+	ResourceURL   string `json:"resource_url"`
+	ResourceLabel string `json:"resource_label"`
+	Model         string `json:"model"`
+	// Inference records the sampling parameters the conversation used.
+	Inference *InferenceConfig `json:"inference,omitempty"`
+	// Usage totals the token counts of every request in the session.
+	Usage   *UsageTotals `json:"usage,omitempty"`
+	Created time.Time    `json:"created"`
+	Updated time.Time    `json:"updated"`
+	History []Message    `json:"history"`
+}
+
+// NewChatSession starts a session about the resource with the given URL.
+// The id sorts by resource and then by start time.
+func NewChatSession(resourceURL, resourceLabel, model string) *ChatSession {
+	now := time.Now()
+	sum := sha1.Sum([]byte(resourceURL))
+	return &ChatSession{
+		ID:            hex.EncodeToString(sum[:4]) + "-" + now.Format("20060102-150405"),
+		ResourceURL:   resourceURL,
+		ResourceLabel: resourceLabel,
+		Model:         model,
+		Created:       now,
+		Updated:       now,
+	}
+}
+
+func sessionsDir() (string, error) {
+	return config.Path("sessions")
+}
+
+// Save writes the session to the sessions directory. Image bytes are replaced
+// with a placeholder to keep session files small.
+func (s *ChatSession) Save() error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	s.Updated = time.Now()
+	saved := *s
+	saved.History = stripImages(s.History)
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, s.ID+".json"), data, 0o644)
+}
+
+// stripImages returns a copy of history with image blocks replaced by text.
+func stripImages(history []Message) []Message {
+	out := make([]Message, len(history))
+	for i, msg := range history {
+		out[i] = Message{Role: msg.Role, Content: make([]ContentBlock, len(msg.Content))}
+		for j, block := range msg.Content {
+			if block.Image != nil {
+				block = ContentBlock{Text: "[image omitted]"}
+			}
+			out[i].Content[j] = block
+		}
+	}
+	return out
+}
+
+// LoadChatSession reads a saved session by id.
+func LoadChatSession(id string) (*ChatSession, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var s ChatSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// ListChatSessions returns all saved sessions, most recently updated first.
+func ListChatSessions() ([]*ChatSession, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var sessions []*ChatSession
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		s, err := LoadChatSession(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Updated.After(sessions[j].Updated) })
+	return sessions, nil
+}
+
+// DeleteChatSession removes a saved session.
+func DeleteChatSession(id string) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, id+".json"))
+}
+
+// Markdown renders the session as a shareable Markdown transcript.
+func (s *ChatSession) Markdown() string {
+	var sb strings.Builder
+	title := s.ResourceLabel
+	if title == "" {
+		title = s.ResourceURL
+	}
+	fmt.Fprintf(&sb, "# Chat: %s\n\n", title)
+	fmt.Fprintf(&sb, "- Resource: <%s>\n", s.ResourceURL)
+	fmt.Fprintf(&sb, "- Model: `%s`\n", s.Model)
+	if s.Inference != nil {
+		fmt.Fprintf(&sb, "- Settings: %s\n", s.Inference)
+	}
+	if s.Usage != nil {
+		fmt.Fprintf(&sb, "- Usage: %s\n", s.Usage)
+	}
+	fmt.Fprintf(&sb, "- Started: %s\n", s.Created.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Updated: %s\n", s.Updated.Format(time.RFC3339))
+
+	for _, msg := range s.History {
+		for _, block := range msg.Content {
+			switch {
+			case block.ToolUse != nil:
+				fmt.Fprintf(&sb, "\n> Tool call: `%s` `%s`\n", block.ToolUse.Name, string(block.ToolUse.Input))
+			case block.ToolResult != nil:
+				for _, c := range block.ToolResult.Content {
+					fmt.Fprintf(&sb, "\n> Tool result:\n>\n> %s\n", strings.ReplaceAll(strings.TrimSpace(c.Text), "\n", "\n> "))
+				}
+			case block.Image != nil:
+				sb.WriteString("\n_[image attached]_\n")
+			case block.Text != "":
+				speaker := "You"
+				if msg.Role == "assistant" {
+					speaker = "Assistant"
+				}
+				fmt.Fprintf(&sb, "\n## %s\n\n%s\n", speaker, strings.TrimSpace(block.Text))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Export writes the transcript to dir as Markdown ("md") or JSON ("json")
+// and returns the path written.
+func (s *ChatSession) Export(dir, format string) (string, error) {
+	var data []byte
+	switch format {
+	case "md", "markdown":
+		format = "md"
+		data = []byte(s.Markdown())
+	case "json":
+		exported := *s
+		exported.History = stripImages(s.History)
+		var err error
+		data, err = json.MarshalIndent(exported, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode session: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unknown export format %q", format)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("loam-chat-%s.%s", s.ID, format))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	return path, nil
+}
+
+// historyLines renders a conversation for the chat viewport.
+func (m *Model) historyLines(history []Message) []string {
+	var lines []string
+	for _, msg := range history {
+		for _, block := range msg.Content {
+			switch {
+			case block.ToolUse != nil:
+				lines = append(lines, toolUseLine(*block.ToolUse))
+			case block.ToolResult != nil && len(block.ToolResult.Content) > 0:
+				lines = append(lines, toolResultLine(block.ToolResult.Content[0].Text))
+			case block.Image != nil:
+				lines = append(lines, ToolStyle.Render("[image attached]"))
+			case block.Text != "" && msg.Role == "assistant":
+				lines = append(lines, assistantLine(block.Text, ""))
+			case block.Text != "":
+				lines = append(lines, m.Chat.SenderStyle.Render("You: ")+block.Text)
+			}
+		}
+	}
+	return lines
+}
+
+func toolUseLine(use ToolUse) string {
+	return ToolStyle.Render(fmt.Sprintf("→ %s %s", use.Name, string(use.Input)))
+}
+
+func toolResultLine(result string) string {
+	return ToolStyle.Render("← " + summarizeToolResult(result))
+}
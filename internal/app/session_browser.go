@@ -0,0 +1,197 @@
+// File: /loam/internal/app/session_browser.go
+
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sessionItem adapts a ChatSession to the bubbles list.
+type sessionItem struct {
+	session *ChatSession
+	// current marks sessions about the resource being browsed.
+	current bool
+}
+
+func (i sessionItem) Title() string {
+	title := i.session.ResourceLabel
+	if title == "" {
+		title = i.session.ResourceURL
+	}
+	if i.current {
+		title = "• " + title
+	}
+	return title
+}
+
+func (i sessionItem) Description() string {
+	return fmt.Sprintf("%s · %d messages · %s",
+		i.session.Updated.Format("2006-01-02 15:04"), len(i.session.History), i.session.Model)
+}
+
+func (i sessionItem) FilterValue() string {
+	return i.session.ResourceLabel + " " + i.session.ResourceURL
+}
+
+// newSessionList creates the list used by the session browser.
+func newSessionList(width, height int) list.Model {
+	l := list.New([]list.Item{}, list.NewDefaultDelegate(), width, height)
+	l.Title = "Chat Sessions"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+	themeList(&l)
+	return l
+}
+
+// currentResource returns the URL and label a new chat session is about.
+func (m *Model) currentResource() (string, string) {
+	if m.ShowDetail {
+		return m.SelectedItem.URL, m.SelectedItem.Title
+	}
+	if m.Resource != nil {
+		return m.Resource.ID, m.Resource.Label
+	}
+	return "", ""
+}
+
+// ensureSession starts a session before the first message is sent.
+func (m *Model) ensureSession() {
+	if m.Chat.Session != nil {
+		return
+	}
+	modelID, _ := ChatCapabilities()
+	url, label := m.currentResource()
+	m.Chat.Session = NewChatSession(url, label, modelID)
+}
+
+// saveSession persists the conversation, reporting failures in the chat.
+func (m *Model) saveSession() {
+	if m.Chat.Session == nil || len(m.Chat.History) == 0 {
+		return
+	}
+	m.Chat.Session.History = m.Chat.History
+	inference := m.Chat.Inference
+	m.Chat.Session.Inference = &inference
+	if err := m.Chat.Session.Save(); err != nil {
+		m.Chat.Messages = append(m.Chat.Messages, ToolStyle.Render("Failed to save session: "+err.Error()))
+	}
+}
+
+// newSession clears the conversation so the next message starts a new session.
+func (m *Model) newSession() {
+	m.saveSession()
+	m.Chat.Session = nil
+	m.Chat.History = nil
+	m.Chat.Messages = nil
+	m.Chat.Hits = nil
+	m.Chat.Citations = nil
+	m.Chat.PendingImage = nil
+	m.refreshChatContext()
+	m.renderChatViewport()
+}
+
+// openSessionBrowser lists saved sessions, marking those about the current resource.
+func (m *Model) openSessionBrowser() {
+	sessions, err := ListChatSessions()
+	if err != nil {
+		m.Chat.Messages = append(m.Chat.Messages, ToolStyle.Render(err.Error()))
+		m.renderChatViewport()
+		return
+	}
+
+	url, _ := m.currentResource()
+	items := make([]list.Item, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, sessionItem{session: s, current: url != "" && s.ResourceURL == url})
+	}
+
+	m.Chat.Sessions = newSessionList(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+	m.Chat.Sessions.SetItems(items)
+	m.Chat.ShowSessions = true
+}
+
+// updateSessionBrowser handles keys while the session browser is open.
+func (m *Model) updateSessionBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	item, _ := m.Chat.Sessions.SelectedItem().(sessionItem)
+
+	if key.Matches(msg, m.Keys.ChatSessions) {
+		m.Chat.ShowSessions = false
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.Chat.ShowSessions = false
+		return m, nil
+
+	case "enter":
+		if item.session == nil {
+			return m, nil
+		}
+		// A reply still on its way would land in the resumed session
+		if m.Chat.InFlight {
+			m.Status = "Wait for the reply before resuming another session."
+			return m, nil
+		}
+		m.saveSession()
+		m.Chat.Session = item.session
+		m.Chat.History = item.session.History
+		if item.session.Inference != nil {
+			m.Chat.Inference = *item.session.Inference
+		}
+		m.Chat.Messages = append(m.historyLines(item.session.History),
+			ToolStyle.Render(fmt.Sprintf("Resumed session from %s.", item.session.Updated.Format("2006-01-02 15:04"))))
+		m.Chat.Hits = nil
+		m.Chat.Citations = nil
+		m.Chat.ShowSessions = false
+		m.Chat.ShowContext = false
+		m.renderChatViewport()
+		return m, nil
+
+	case "m", "j":
+		if item.session == nil {
+			return m, nil
+		}
+		format := "md"
+		if msg.String() == "j" {
+			format = "json"
+		}
+		dir, err := os.Getwd()
+		if err == nil {
+			var path string
+			path, err = item.session.Export(dir, format)
+			if err == nil {
+				m.Status = "Exported chat to " + path
+			}
+		}
+		if err != nil {
+			m.Status = "Export failed: " + err.Error()
+		}
+		return m, nil
+
+	case "d":
+		if item.session == nil {
+			return m, nil
+		}
+		if err := DeleteChatSession(item.session.ID); err != nil {
+			m.Status = "Delete failed: " + err.Error()
+			return m, nil
+		}
+		if m.Chat.Session != nil && m.Chat.Session.ID == item.session.ID {
+			m.Chat.Session = nil
+		}
+		m.Chat.Sessions.RemoveItem(m.Chat.Sessions.Index())
+		m.Status = "Deleted chat session."
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.Chat.Sessions, cmd = m.Chat.Sessions.Update(msg)
+	return m, cmd
+}
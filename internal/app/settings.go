@@ -0,0 +1,273 @@
+// File: /loam/internal/app/settings.go
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// InferenceFromConfig converts configured sampling parameters to the request format.
+func InferenceFromConfig(cfg config.InferenceConfig) InferenceConfig {
+	return InferenceConfig{
+		MaxNewTokens:  cfg.MaxTokens,
+		Temperature:   cfg.Temperature,
+		TopP:          cfg.TopP,
+		TopK:          cfg.TopK,
+		StopSequences: cfg.StopSequences,
+	}
+}
+
+// Validate checks the parameters against the limits of a model.
+func (inf InferenceConfig) Validate(caps ModelCapabilities) error {
+	if inf.MaxNewTokens < 0 {
+		return fmt.Errorf("max tokens must be positive")
+	}
+	if caps.MaxOutputTokens > 0 && inf.MaxNewTokens > caps.MaxOutputTokens {
+		return fmt.Errorf("max tokens must be at most %d", caps.MaxOutputTokens)
+	}
+	if t := inf.Temperature; t != nil && (*t < 0 || *t > 1) {
+		return fmt.Errorf("temperature must be between 0 and 1")
+	}
+	if p := inf.TopP; p != nil && (*p <= 0 || *p > 1) {
+		return fmt.Errorf("top-p must be greater than 0 and at most 1")
+	}
+	if k := inf.TopK; k != nil {
+		if *k < 0 {
+			return fmt.Errorf("top-k must not be negative")
+		}
+		if caps.MaxTopK > 0 && *k > caps.MaxTopK {
+			return fmt.Errorf("top-k must be at most %d", caps.MaxTopK)
+		}
+	}
+	for _, seq := range inf.StopSequences {
+		if seq == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// String summarizes the parameters that are set, e.g. "max tokens 1000, temperature 0.2".
+func (inf InferenceConfig) String() string {
+	var parts []string
+	if inf.MaxNewTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max tokens %d", inf.MaxNewTokens))
+	}
+	if inf.Temperature != nil {
+		parts = append(parts, "temperature "+formatFloat(*inf.Temperature))
+	}
+	if inf.TopP != nil {
+		parts = append(parts, "top-p "+formatFloat(*inf.TopP))
+	}
+	if inf.TopK != nil {
+		parts = append(parts, fmt.Sprintf("top-k %d", *inf.TopK))
+	}
+	if len(inf.StopSequences) > 0 {
+		parts = append(parts, fmt.Sprintf("stop %q", inf.StopSequences))
+	}
+	if len(parts) == 0 {
+		return "model defaults"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// settingsFields are the inputs of the settings overlay, in order.
+var settingsFields = []struct {
+	label       string
+	placeholder string
+}{
+	{"Temperature", "0-1, blank for model default"},
+	{"Top P", "0-1, blank for model default"},
+	{"Top K", "blank for model default"},
+	{"Max tokens", "blank for 1000"},
+	{"Stop sequences", "comma-separated, or a JSON array"},
+}
+
+// openSettings shows the inference settings overlay, filled in with the
+// current values.
+func (m *Model) openSettings() tea.Cmd {
+	inf := m.Chat.Inference
+	values := make([]string, len(settingsFields))
+	if inf.Temperature != nil {
+		values[0] = formatFloat(*inf.Temperature)
+	}
+	if inf.TopP != nil {
+		values[1] = formatFloat(*inf.TopP)
+	}
+	if inf.TopK != nil {
+		values[2] = strconv.Itoa(*inf.TopK)
+	}
+	if inf.MaxNewTokens > 0 {
+		values[3] = strconv.Itoa(inf.MaxNewTokens)
+	}
+	values[4] = formatStopSequences(inf.StopSequences)
+
+	m.Chat.Settings = make([]textinput.Model, len(settingsFields))
+	for i, field := range settingsFields {
+		ti := textinput.New()
+		ti.Prompt = fmt.Sprintf("%-16s", field.label+":")
+		ti.Placeholder = field.placeholder
+		ti.SetValue(values[i])
+		themeTextInput(&ti)
+		m.Chat.Settings[i] = ti
+	}
+	m.Chat.SettingsFocus = 0
+	m.Chat.SettingsErr = ""
+	m.Chat.ShowSettings = true
+	return m.Chat.Settings[0].Focus()
+}
+
+// parseSettings reads the overlay's inputs back into inference parameters.
+func (m *Model) parseSettings() (InferenceConfig, error) {
+	var inf InferenceConfig
+	value := func(i int) string { return strings.TrimSpace(m.Chat.Settings[i].Value()) }
+
+	if v := value(0); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return inf, fmt.Errorf("temperature must be a number")
+		}
+		inf.Temperature = &t
+	}
+	if v := value(1); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return inf, fmt.Errorf("top-p must be a number")
+		}
+		inf.TopP = &p
+	}
+	if v := value(2); v != "" {
+		k, err := strconv.Atoi(v)
+		if err != nil {
+			return inf, fmt.Errorf("top-k must be a whole number")
+		}
+		inf.TopK = &k
+	}
+	if v := value(3); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return inf, fmt.Errorf("max tokens must be a whole number")
+		}
+		inf.MaxNewTokens = n
+	}
+	// Stop sequences are kept as they are unless the field was edited
+	if raw := m.Chat.Settings[4].Value(); raw == formatStopSequences(m.Chat.Inference.StopSequences) {
+		inf.StopSequences = m.Chat.Inference.StopSequences
+	} else {
+		seqs, err := parseStopSequences(raw)
+		if err != nil {
+			return inf, err
+		}
+		inf.StopSequences = seqs
+	}
+
+	_, caps := ChatCapabilities()
+	return inf, inf.Validate(caps)
+}
+
+// formatStopSequences shows stop sequences comma-separated, or as a JSON
+// array when that would lose commas or surrounding spaces.
+func formatStopSequences(seqs []string) string {
+	plain := true
+	for _, seq := range seqs {
+		if seq == "" || strings.Contains(seq, ",") || strings.TrimSpace(seq) != seq {
+			plain = false
+		}
+	}
+	joined := strings.Join(seqs, ", ")
+	if plain && !strings.HasPrefix(joined, "[") {
+		return joined
+	}
+	data, _ := json.Marshal(seqs)
+	return string(data)
+}
+
+// parseStopSequences reads stop sequences written as formatStopSequences
+// shows them.
+func parseStopSequences(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		var seqs []string
+		if err := json.Unmarshal([]byte(s), &seqs); err != nil {
+			return nil, fmt.Errorf("stop sequences must be a JSON array of strings")
+		}
+		return seqs, nil
+	}
+	var seqs []string
+	for _, seq := range strings.Split(s, ",") {
+		if seq = strings.TrimSpace(seq); seq != "" {
+			seqs = append(seqs, seq)
+		}
+	}
+	return seqs, nil
+}
+
+// updateSettings handles keys while the settings overlay is open.
+func (m *Model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, m.Keys.Settings) {
+		m.Chat.ShowSettings = false
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.Chat.ShowSettings = false
+		return m, nil
+
+	case "tab", "down":
+		return m, m.focusSetting(m.Chat.SettingsFocus + 1)
+
+	case "shift+tab", "up":
+		return m, m.focusSetting(m.Chat.SettingsFocus - 1)
+
+	case "enter":
+		inf, err := m.parseSettings()
+		if err != nil {
+			m.Chat.SettingsErr = err.Error()
+			return m, nil
+		}
+		m.Chat.Inference = inf
+		m.Chat.ShowSettings = false
+		m.Status = "Chat settings: " + inf.String()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.Chat.Settings[m.Chat.SettingsFocus], cmd = m.Chat.Settings[m.Chat.SettingsFocus].Update(msg)
+	return m, cmd
+}
+
+// focusSetting moves the cursor to the i-th input, wrapping around.
+func (m *Model) focusSetting(i int) tea.Cmd {
+	n := len(m.Chat.Settings)
+	m.Chat.Settings[m.Chat.SettingsFocus].Blur()
+	m.Chat.SettingsFocus = (i%n + n) % n
+	return m.Chat.Settings[m.Chat.SettingsFocus].Focus()
+}
+
+// settingsView renders the settings overlay.
+func (m *Model) settingsView() string {
+	lines := []string{TitleStyle.Render("Chat Settings"), ""}
+	for _, ti := range m.Chat.Settings {
+		lines = append(lines, ti.View())
+	}
+	lines = append(lines, "")
+	if m.Chat.SettingsErr != "" {
+		lines = append(lines, WarningStyle.Render(m.Chat.SettingsErr))
+	}
+	lines = append(lines, HelpStyle.Render("Tab/↑/↓: Move | Enter: Apply | Esc: Cancel"))
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStopSequencesRoundTrip(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"END", "STOP"},
+		{"a, b", "END"},
+		{" indented", "trailing "},
+		{"[done]"},
+	}
+	for _, seqs := range tests {
+		got, err := parseStopSequences(formatStopSequences(seqs))
+		if err != nil {
+			t.Errorf("%q: %v", seqs, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, seqs) {
+			t.Errorf("%q came back as %q", seqs, got)
+		}
+	}
+}
+
+func TestSettingsKeepUneditedStopSequences(t *testing.T) {
+	m := chatModel(t, 5)
+	m.Chat.Inference.StopSequences = []string{"a, b", " END"}
+	m.openSettings()
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if m.Chat.ShowSettings {
+		t.Fatalf("settings still open: %s", m.Chat.SettingsErr)
+	}
+	if want := []string{"a, b", " END"}; !reflect.DeepEqual(m.Chat.Inference.StopSequences, want) {
+		t.Errorf("stop sequences = %q, want %q", m.Chat.Inference.StopSequences, want)
+	}
+}
@@ -2,39 +2,227 @@
 
 package app
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"os"
+
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the theme the styles below were last set from by ApplyTheme.
+var Theme ui.Theme
 
 var (
+	TitleStyle         lipgloss.Style
+	BorderStyle        lipgloss.Style
+	FocusedBorderStyle lipgloss.Style
+	HelpStyle          lipgloss.Style
+	NoItemsStyle       lipgloss.Style
+	SpinnerStyle       lipgloss.Style
+
+	// New Style for Focused "LoamIIIF" Title
+	FocusedTitleStyle lipgloss.Style
+
+	// AssistantStyle for Assistant messages, and SenderStyle for the user's
+	AssistantStyle lipgloss.Style
+	SenderStyle    lipgloss.Style
+
+	// ToolStyle for tool calls and results in the chat viewport
+	ToolStyle lipgloss.Style
+
+	// WarningStyle for chat setup problems
+	WarningStyle lipgloss.Style
+
+	// CitationStyle for reference ids cited in assistant replies, and
+	// SelectedCitationStyle for the one selected with ctrl+g
+	CitationStyle         lipgloss.Style
+	SelectedCitationStyle lipgloss.Style
+
+	// DiffRemovedStyle and DiffAddedStyle show current and proposed values
+	// when reviewing metadata enrichment
+	DiffRemovedStyle lipgloss.Style
+	DiffAddedStyle   lipgloss.Style
+
+	// TabStyle and ActiveTabStyle for the tab bar next to the title
+	TabStyle       lipgloss.Style
+	ActiveTabStyle lipgloss.Style
+
+	// BreadcrumbStyle for the collections above the current list, and
+	// CurrentCrumbStyle for the current one
+	BreadcrumbStyle   lipgloss.Style
+	CurrentCrumbStyle lipgloss.Style
+)
+
+func init() {
+	t, _ := ui.BuiltinTheme(ui.DefaultTheme)
+	ApplyTheme(t)
+}
+
+// ApplyTheme sets the styles from the colors of t. Components built
+// afterwards pick them up; see the helpers below for bubbles components.
+func ApplyTheme(t ui.Theme) {
+	Theme = t
+
 	TitleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("205"))
+		Bold(true).
+		Foreground(t.Accent)
 
 	BorderStyle = lipgloss.NewStyle().
-			Border(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("240")).
-			Padding(0, 1)
+		Border(lipgloss.ThickBorder()).
+		BorderForeground(t.Border).
+		Padding(0, 1)
 
 	FocusedBorderStyle = lipgloss.NewStyle().
-				Border(lipgloss.ThickBorder()).
-				BorderForeground(lipgloss.Color("205")).
-				Padding(0, 1)
+		Border(lipgloss.ThickBorder()).
+		BorderForeground(t.Accent).
+		Padding(0, 1)
+	if t.NoColor {
+		// Without color, a double border marks the focus
+		FocusedBorderStyle = FocusedBorderStyle.Border(lipgloss.DoubleBorder())
+	}
 
 	HelpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241")).
-			Italic(true)
+		Foreground(t.Muted).
+		Italic(true)
 
 	NoItemsStyle = lipgloss.NewStyle().Margin(1, 0)
 
-	SpinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	SpinnerStyle = lipgloss.NewStyle().Foreground(t.Accent)
 
-	// New Style for Focused "LoamIIIF" Title
 	FocusedTitleStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("206")).
-				Underline(true) // Optional: Adds underline to indicate focus
+		Bold(true).
+		Foreground(t.Focus).
+		Underline(true) // Optional: Adds underline to indicate focus
 
-	// AssistantStyle for Assistant messages
 	AssistantStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("42")) // Choose a distinct color for Assistant
-)
+		Bold(true).
+		Foreground(t.Success)
+
+	SenderStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(t.Accent)
+
+	ToolStyle = lipgloss.NewStyle().
+		Foreground(t.Muted)
+
+	WarningStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(t.Warning)
+
+	CitationStyle = lipgloss.NewStyle().
+		Foreground(t.Link)
+
+	SelectedCitationStyle = lipgloss.NewStyle().
+		Bold(true).
+		Reverse(true).
+		Foreground(t.Link)
+
+	DiffRemovedStyle = lipgloss.NewStyle().
+		Foreground(t.Danger)
+
+	DiffAddedStyle = lipgloss.NewStyle().
+		Foreground(t.Success)
+	if t.NoColor {
+		DiffRemovedStyle = DiffRemovedStyle.Strikethrough(true)
+		DiffAddedStyle = DiffAddedStyle.Bold(true)
+	}
+
+	TabStyle = lipgloss.NewStyle().
+		Foreground(t.Muted).
+		Padding(0, 1)
+
+	ActiveTabStyle = lipgloss.NewStyle().
+		Bold(true).
+		Reverse(true).
+		Foreground(t.Accent).
+		Padding(0, 1)
+
+	BreadcrumbStyle = lipgloss.NewStyle().
+		Foreground(t.Muted)
+
+	CurrentCrumbStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(t.Text)
+}
+
+// loadTheme applies the named theme, or the default one if it can't be
+// loaded. NO_COLOR turns color off whatever the theme.
+func loadTheme(name string) error {
+	theme, err := ui.LoadTheme(name)
+	if err != nil {
+		theme, _ = ui.BuiltinTheme(ui.DefaultTheme)
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		theme = ui.NoColorTheme()
+	}
+	ApplyTheme(theme)
+	return err
+}
+
+// helpStyles colors the footer and help overlay.
+func helpStyles() help.Styles {
+	s := help.New().Styles
+	s.ShortKey = lipgloss.NewStyle().Foreground(Theme.Text)
+	s.ShortDesc = lipgloss.NewStyle().Foreground(Theme.Muted)
+	s.ShortSeparator = lipgloss.NewStyle().Foreground(Theme.Border)
+	s.Ellipsis = s.ShortSeparator
+	s.FullKey = s.ShortKey
+	s.FullDesc = s.ShortDesc
+	s.FullSeparator = s.ShortSeparator
+	return s
+}
+
+// themeList colors a list built with the default delegate, such as the
+// history and session browser.
+func themeList(l *list.Model) {
+	d := list.NewDefaultDelegate()
+	d.Styles.NormalTitle = d.Styles.NormalTitle.Foreground(Theme.Text)
+	d.Styles.NormalDesc = d.Styles.NormalDesc.Foreground(Theme.Muted)
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(Theme.Accent).BorderForeground(Theme.Accent)
+	d.Styles.SelectedDesc = d.Styles.SelectedDesc.Foreground(Theme.Accent).BorderForeground(Theme.Accent)
+	d.Styles.DimmedTitle = d.Styles.DimmedTitle.Foreground(Theme.Muted)
+	d.Styles.DimmedDesc = d.Styles.DimmedDesc.Foreground(Theme.Border)
+	d.Styles.FilterMatch = d.Styles.FilterMatch.Underline(true)
+	l.SetDelegate(d)
+	themeListChrome(l)
+}
+
+// themeListChrome colors a list's title, filter box and pagination.
+func themeListChrome(l *list.Model) {
+	l.Styles.Title = TitleStyle
+	l.Styles.NoItems = NoItemsStyle
+	l.Styles.FilterPrompt = lipgloss.NewStyle().Foreground(Theme.Accent)
+	l.Styles.FilterCursor = lipgloss.NewStyle().Foreground(Theme.Accent)
+	l.Styles.DefaultFilterCharacterMatch = lipgloss.NewStyle().Underline(true)
+	l.Styles.ActivePaginationDot = lipgloss.NewStyle().Foreground(Theme.Text).SetString("•")
+	l.Styles.InactivePaginationDot = lipgloss.NewStyle().Foreground(Theme.Border).SetString("•")
+	l.Styles.ArabicPagination = lipgloss.NewStyle().Foreground(Theme.Muted)
+	l.Styles.DividerDot = lipgloss.NewStyle().Foreground(Theme.Border).SetString(" • ")
+	l.FilterInput.PromptStyle = l.Styles.FilterPrompt
+	l.FilterInput.Cursor.Style = l.Styles.FilterCursor
+}
+
+// themeTextArea colors a text area's prompt and placeholder.
+func themeTextArea(ta *textarea.Model) {
+	for _, s := range []*textarea.Style{&ta.FocusedStyle, &ta.BlurredStyle} {
+		s.Placeholder = lipgloss.NewStyle().Foreground(Theme.Muted)
+		s.Prompt = lipgloss.NewStyle().Foreground(Theme.Accent)
+	}
+	ta.BlurredStyle.Text = lipgloss.NewStyle().Foreground(Theme.Muted)
+	ta.BlurredStyle.CursorLine = ta.BlurredStyle.Text
+	if Theme.NoColor {
+		ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	}
+	ta.Cursor.Style = lipgloss.NewStyle().Foreground(Theme.Accent)
+}
+
+// themeTextInput colors a text input's prompt and placeholder.
+func themeTextInput(ti *textinput.Model) {
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(Theme.Accent)
+	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(Theme.Muted)
+	ti.Cursor.Style = lipgloss.NewStyle().Foreground(Theme.Accent)
+}
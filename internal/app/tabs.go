@@ -0,0 +1,353 @@
+// File: /loam/internal/app/tabs.go
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/index"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Tab is one browsing session: its URL, results list, navigation stack,
+// detail pane and chat. The active tab's state lives in the Model's own
+// fields; it is copied into its Tab when another tab is shown.
+type Tab struct {
+	Name string // Set by renaming; otherwise the tab shows its list's label
+	URL  string // The URL input
+
+	Items          []list.Item
+	Index          int
+	InList         bool
+	PrevItemsStack []NavLevel
+	Level          NavLevel
+	Resource       *iiif.Resource
+	SemanticIndex  *index.Index
+	Back, Forward  []navState
+
+	ShowDetail     bool
+	SelectedItem   ui.Item
+	DetailResource *iiif.Resource
+	DetailData     []byte
+	CanvasIndex    int
+
+	ShowChat bool
+	Chat     ChatModel
+
+	// fetch is set for tabs restored from a previous run, whose URL is
+	// fetched when the tab is first shown.
+	fetch bool
+}
+
+// title is what the tab bar shows for the tab.
+func (t *Tab) title() string {
+	return tabTitle(t.Name, t.Level.Label, t.URL)
+}
+
+func tabTitle(name, label, url string) string {
+	switch {
+	case name != "":
+		return name
+	case label != "":
+		return truncateLabel(label, 20)
+	case url != "":
+		return truncateLabel(url, 20)
+	}
+	return "New tab"
+}
+
+// saveTab copies the active tab's state out of the model.
+func (m *Model) saveTab() {
+	t := m.Tabs[m.ActiveTab]
+	t.URL = m.TextArea.Value()
+	t.Items = m.List.Items()
+	t.Index = m.selectedIndex()
+	t.InList = m.InList
+	t.PrevItemsStack = m.PrevItemsStack
+	t.Level = m.Level
+	t.Resource = m.Resource
+	t.SemanticIndex = m.Index
+	t.Back, t.Forward = m.Back, m.Forward
+	t.ShowDetail = m.ShowDetail
+	t.SelectedItem = m.SelectedItem
+	t.DetailResource = m.DetailResource
+	t.DetailData = m.DetailData
+	t.CanvasIndex = m.CanvasIndex
+	t.ShowChat = m.ShowChat
+	t.Chat = m.Chat
+}
+
+// loadTab shows tab i, fetching its URL if it was restored and not yet shown.
+func (m *Model) loadTab(i int) tea.Cmd {
+	t := m.Tabs[i]
+	m.ActiveTab = i
+
+	m.TextArea.SetValue(t.URL)
+	m.List.ResetFilter()
+	m.List.SetItems(t.Items)
+	m.List.Select(t.Index)
+	m.InList = t.InList
+	if m.InList {
+		m.TextArea.Blur()
+	} else {
+		m.TextArea.Focus()
+	}
+	m.PrevItemsStack = t.PrevItemsStack
+	m.Level = t.Level
+	m.Resource = t.Resource
+	m.Index = t.SemanticIndex
+	m.Back, m.Forward = t.Back, t.Forward
+	m.ShowDetail = t.ShowDetail
+	m.SelectedItem = t.SelectedItem
+	m.DetailResource = t.DetailResource
+	m.DetailData = t.DetailData
+	m.CanvasIndex = t.CanvasIndex
+	m.pendingLevel = nil
+	m.pendingCanvas = ""
+	m.previewURL, m.previewErr = "", nil
+
+	// The chat keeps the size of the window it is shown in
+	chat := t.Chat
+	chat.Viewport.Width, chat.Viewport.Height = m.Chat.Viewport.Width, m.Chat.Viewport.Height
+	chat.TextArea.SetWidth(m.Chat.TextArea.Width())
+	chat.Sessions.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+	chat.Templates.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+	m.Chat = chat
+	m.ShowChat = t.ShowChat
+	m.refreshChatContext()
+	m.renderChatViewport()
+
+	if t.fetch {
+		t.fetch = false
+		if t.URL != "" {
+			return m.loadURL(t.URL)
+		}
+	}
+	m.Status = "Switched to " + t.title()
+	return nil
+}
+
+// busy reports whether a fetch or chat reply is on its way to the active
+// tab, which would arrive in the wrong tab after switching.
+func (m *Model) busy() bool {
+	return m.Loading || m.Chat.InFlight
+}
+
+// newTab opens an empty tab after the active one and shows it.
+func (m *Model) newTab() tea.Cmd {
+	if m.busy() {
+		m.Status = "Wait for the current request to finish before opening a tab."
+		return nil
+	}
+	m.saveTab()
+	chat := InitialChatModel()
+	chat.Inference = m.Chat.Inference
+	t := &Tab{Chat: chat}
+	m.Tabs = append(m.Tabs[:m.ActiveTab+1], append([]*Tab{t}, m.Tabs[m.ActiveTab+1:]...)...)
+	m.loadTab(m.ActiveTab + 1)
+	m.Status = "Opened a new tab. Enter a IIIF URL."
+	return nil
+}
+
+// closeTab closes the active tab and shows its neighbour. The last tab can't
+// be closed.
+func (m *Model) closeTab() tea.Cmd {
+	if len(m.Tabs) == 1 {
+		m.Status = "This is the only tab."
+		return nil
+	}
+	if m.busy() {
+		m.Status = "Wait for the current request to finish before closing the tab."
+		return nil
+	}
+	m.saveSession()
+	closed := m.Tabs[m.ActiveTab].title()
+	m.Tabs = append(m.Tabs[:m.ActiveTab], m.Tabs[m.ActiveTab+1:]...)
+	i := m.ActiveTab
+	if i >= len(m.Tabs) {
+		i = len(m.Tabs) - 1
+	}
+	cmd := m.loadTab(i)
+	m.Status = "Closed " + closed
+	return cmd
+}
+
+// switchTab shows tab i.
+func (m *Model) switchTab(i int) tea.Cmd {
+	if i < 0 || i >= len(m.Tabs) || i == m.ActiveTab {
+		return nil
+	}
+	if m.busy() {
+		m.Status = "Wait for the current request to finish before switching tabs."
+		return nil
+	}
+	m.saveTab()
+	return m.loadTab(i)
+}
+
+// updateTabKeys handles the keys that open, close, rename and switch tabs,
+// which work everywhere. It reports whether it handled the key.
+func (m *Model) updateTabKeys(msg tea.KeyMsg) (bool, tea.Cmd) {
+	k := m.Keys
+	switch {
+	case key.Matches(msg, k.NewTab):
+		return true, m.newTab()
+	case key.Matches(msg, k.CloseTab):
+		return true, m.closeTab()
+	case key.Matches(msg, k.NextTab):
+		return true, m.switchTab((m.ActiveTab + 1) % len(m.Tabs))
+	case key.Matches(msg, k.PrevTab):
+		return true, m.switchTab((m.ActiveTab - 1 + len(m.Tabs)) % len(m.Tabs))
+	case key.Matches(msg, k.RenameTab):
+		m.TabPrompt.SetValue(m.Tabs[m.ActiveTab].Name)
+		m.TabPrompt.CursorEnd()
+		m.ShowTabPrompt = true
+		return true, m.TabPrompt.Focus()
+	case key.Matches(msg, k.SwitchTab):
+		return true, m.switchTab(keyIndex(k.SwitchTab, msg))
+	}
+	return false, nil
+}
+
+// updateTabPrompt handles keys while renaming the active tab.
+func (m *Model) updateTabPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.ShowTabPrompt = false
+		m.TabPrompt.Blur()
+		return m, nil
+	case "enter":
+		// An empty name goes back to showing the list's label
+		m.Tabs[m.ActiveTab].Name = strings.TrimSpace(m.TabPrompt.Value())
+		m.ShowTabPrompt = false
+		m.TabPrompt.Blur()
+		m.Status = "Renamed tab to " + tabTitle(m.Tabs[m.ActiveTab].Name, m.Level.Label, m.TextArea.Value())
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.TabPrompt, cmd = m.TabPrompt.Update(msg)
+	return m, cmd
+}
+
+// tabBar renders the tabs after the title, when there is more than one,
+// marking each as a zone to click.
+func (m *Model) tabBar() string {
+	if len(m.Tabs) < 2 {
+		return ""
+	}
+	var tabs []string
+	x := 0
+	for i, t := range m.Tabs {
+		var tab string
+		if i == m.ActiveTab {
+			// The active tab's label follows the list being shown
+			title := tabTitle(t.Name, m.Level.Label, m.TextArea.Value())
+			tab = ActiveTabStyle.Render(fmt.Sprintf("%d %s", i+1, title))
+		} else {
+			tab = TabStyle.Render(fmt.Sprintf("%d %s", i+1, t.title()))
+		}
+		m.mark(zone{ID: zoneTab, N: i, X: x, W: lipgloss.Width(tab), H: 1})
+		x += lipgloss.Width(tab)
+		tabs = append(tabs, tab)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+}
+
+// savedTab is a tab as stored in tabs.json between runs.
+type savedTab struct {
+	Name      string `json:"name,omitempty"`
+	URL       string `json:"url"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type savedTabs struct {
+	Active int        `json:"active"`
+	Tabs   []savedTab `json:"tabs"`
+}
+
+func tabsPath() (string, error) {
+	return config.Path("tabs.json")
+}
+
+// SaveTabs records the open tabs' URLs and chat sessions so the next run
+// can restore them, if restoring tabs is enabled.
+func (m *Model) SaveTabs() error {
+	if !m.RestoreTabs {
+		return nil
+	}
+	m.saveSession()
+	m.saveTab()
+
+	saved := savedTabs{Active: m.ActiveTab}
+	for _, t := range m.Tabs {
+		st := savedTab{Name: t.Name, URL: t.URL}
+		if t.Chat.Session != nil && len(t.Chat.History) > 0 {
+			st.SessionID = t.Chat.Session.ID
+		}
+		saved.Tabs = append(saved.Tabs, st)
+	}
+
+	path, err := tabsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tabs: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// restoreTabs opens the tabs saved by the last run, resuming their chat
+// sessions. The active tab's URL is fetched by Init and the others' when
+// they are first shown.
+func (m *Model) restoreTabs() {
+	path, err := tabsPath()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var saved savedTabs
+	if json.Unmarshal(data, &saved) != nil || len(saved.Tabs) == 0 {
+		return
+	}
+
+	m.Tabs = nil
+	for _, st := range saved.Tabs {
+		chat := InitialChatModel()
+		chat.Inference = m.Chat.Inference
+		if st.SessionID != "" {
+			if session, err := LoadChatSession(st.SessionID); err == nil {
+				chat.Session = session
+				chat.History = session.History
+				chat.Messages = m.historyLines(session.History)
+			}
+		}
+		m.Tabs = append(m.Tabs, &Tab{Name: st.Name, URL: st.URL, Chat: chat, fetch: st.URL != ""})
+	}
+	if saved.Active < 0 || saved.Active >= len(m.Tabs) {
+		saved.Active = 0
+	}
+	active := m.Tabs[saved.Active]
+	active.fetch = false
+	m.loadTab(saved.Active)
+	m.startURL = active.URL
+}
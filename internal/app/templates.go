@@ -0,0 +1,228 @@
+// File: /loam/internal/app/templates.go
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PromptTemplate is a named, reusable prompt written as a Go text/template.
+type PromptTemplate struct {
+	Name        string
+	Description string
+	Text        string
+}
+
+// TemplateData is the data available to prompt templates.
+type TemplateData struct {
+	Resource *iiif.Resource // The fetched collection or manifest
+	Detail   *iiif.Resource // The manifest open in the detail pane, if any
+	Items    []ui.Item      // The items in the current list
+	Input    string         // Extra text typed by the user
+}
+
+// builtinTemplates are always available; user templates with the same name
+// replace them.
+var builtinTemplates = []PromptTemplate{
+	{
+		Name: "translate-titles",
+		Text: `{{/* Translate the titles of the listed items into English */}}
+Translate the following titles into English{{if .Input}} ({{.Input}}){{end}}. Give the original title followed by the translation.
+{{range .Items}}- {{.Title}}
+{{end}}`,
+	},
+	{
+		Name: "summarize-collection",
+		Text: `{{/* Summarize the current collection or manifest */}}
+{{with .Detail}}Summarize the manifest "{{.Label}}"{{else}}{{with .Resource}}Summarize the {{lower .Type}} "{{.Label}}"{{else}}Summarize the current resource{{end}}{{end}} in one paragraph for a general audience, drawing on its metadata and the items it contains.{{if .Input}} {{.Input}}{{end}}`,
+	},
+	{
+		Name: "suggest-subject-headings",
+		Text: `{{/* Suggest Library of Congress subject headings */}}
+Suggest up to five Library of Congress Subject Headings for {{with .Detail}}the manifest "{{.Label}}"{{else}}{{with .Resource}}the {{lower .Type}} "{{.Label}}"{{end}}{{end}}. For each heading, explain briefly which metadata supports it.{{if .Input}} {{.Input}}{{end}}`,
+	},
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// descriptionPattern matches a leading {{/* description */}} comment.
+var descriptionPattern = regexp.MustCompile(`^\{\{/\*\s*(.*?)\s*\*/\}\}\n?`)
+
+// newPromptTemplate builds a template, taking its description from a
+// leading template comment.
+func newPromptTemplate(name, text string) PromptTemplate {
+	t := PromptTemplate{Name: name, Text: text}
+	if m := descriptionPattern.FindStringSubmatch(text); m != nil {
+		t.Description = m[1]
+	}
+	return t
+}
+
+// LoadTemplates returns the built-in templates merged with *.tmpl files from
+// the templates directory in the config dir, sorted by name.
+func LoadTemplates() ([]PromptTemplate, error) {
+	byName := make(map[string]PromptTemplate)
+	for _, t := range builtinTemplates {
+		byName[t.Name] = newPromptTemplate(t.Name, t.Text)
+	}
+
+	dir, err := config.Path("templates")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		byName[name] = newPromptTemplate(name, string(data))
+	}
+
+	templates := make([]PromptTemplate, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// FindTemplate loads the templates and returns the one with the given name.
+func FindTemplate(name string) (PromptTemplate, error) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		return PromptTemplate{}, err
+	}
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return PromptTemplate{}, fmt.Errorf("no template named %q", name)
+}
+
+// Render executes the template against data.
+func (t PromptTemplate) Render(data TemplateData) (string, error) {
+	tmpl, err := template.New(t.Name).Funcs(templateFuncs).Parse(t.Text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", t.Name, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.Name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// templateItem adapts a PromptTemplate to the bubbles list.
+type templateItem struct {
+	tmpl PromptTemplate
+}
+
+func (i templateItem) Title() string       { return i.tmpl.Name }
+func (i templateItem) Description() string { return i.tmpl.Description }
+func (i templateItem) FilterValue() string { return i.tmpl.Name }
+
+// newTemplateList creates the list used by the template palette.
+func newTemplateList(width, height int) list.Model {
+	l := list.New([]list.Item{}, list.NewDefaultDelegate(), width, height)
+	l.Title = "Prompt Templates"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+	themeList(&l)
+	return l
+}
+
+// templateData collects what the browser currently shows for template rendering.
+func (m *Model) templateData(input string) TemplateData {
+	data := TemplateData{Resource: m.Resource, Input: input}
+	if m.ShowDetail {
+		data.Detail = m.DetailResource
+	}
+	for _, li := range m.List.Items() {
+		if item, ok := li.(ui.Item); ok {
+			data.Items = append(data.Items, item)
+		}
+	}
+	return data
+}
+
+// openTemplatePalette lists the available prompt templates in the chat panel.
+func (m *Model) openTemplatePalette() {
+	templates, err := LoadTemplates()
+	if err != nil {
+		m.Chat.Messages = append(m.Chat.Messages, ToolStyle.Render(err.Error()))
+		m.renderChatViewport()
+		return
+	}
+
+	items := make([]list.Item, 0, len(templates))
+	for _, t := range templates {
+		items = append(items, templateItem{tmpl: t})
+	}
+	m.Chat.Templates = newTemplateList(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+	m.Chat.Templates.SetItems(items)
+	m.Chat.ShowTemplates = true
+}
+
+// updateTemplatePalette handles keys while the template palette is open.
+// Choosing a template renders it, using any typed text as .Input, and sends it.
+func (m *Model) updateTemplatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, m.Keys.Templates) {
+		m.Chat.ShowTemplates = false
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.Chat.ShowTemplates = false
+		return m, nil
+
+	case "enter":
+		item, ok := m.Chat.Templates.SelectedItem().(templateItem)
+		if !ok {
+			return m, nil
+		}
+		m.Chat.ShowTemplates = false
+		if m.Chat.InFlight {
+			m.Status = "Wait for the reply before sending another message."
+			return m, nil
+		}
+
+		input := strings.TrimSpace(m.Chat.TextArea.Value())
+		prompt, err := item.tmpl.Render(m.templateData(input))
+		if err != nil {
+			m.Chat.Messages = append(m.Chat.Messages, m.Chat.SenderStyle.Render("Error: ")+err.Error())
+			m.renderChatViewport()
+			return m, nil
+		}
+		m.Chat.TextArea.Reset()
+		return m, m.sendPrompt(fmt.Sprintf("[%s] %s", item.tmpl.Name, prompt), prompt)
+	}
+
+	var cmd tea.Cmd
+	m.Chat.Templates, cmd = m.Chat.Templates.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,329 @@
+// File: /loam/internal/app/tools.go
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/ui"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxSearchManifests caps how many manifests search_collection will fetch.
+const maxSearchManifests = 50
+
+// ToolConfig is the toolConfig block of a Bedrock request.
+type ToolConfig struct {
+	Tools []ToolDefinition `json:"tools"`
+}
+
+// ToolDefinition wraps a ToolSpec as Bedrock expects.
+type ToolDefinition struct {
+	ToolSpec ToolSpec `json:"toolSpec"`
+}
+
+// ToolSpec describes a tool to the model.
+type ToolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema struct {
+		JSON map[string]interface{} `json:"json"`
+	} `json:"inputSchema"`
+}
+
+// ChatTool is an operation the model may invoke during a conversation.
+type ChatTool struct {
+	Spec ToolSpec
+	Run  func(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// Toolbox holds the tools offered to the model.
+type Toolbox struct {
+	Tools []ChatTool
+
+	// Builder renders fetched resources into tool results.
+	Builder ContextBuilder
+}
+
+// NewToolbox returns a Toolbox with the built-in IIIF tools.
+func NewToolbox() *Toolbox {
+	tb := &Toolbox{
+		Builder: ContextBuilder{TokenBudget: 2000},
+	}
+	tb.Tools = []ChatTool{
+		newTool("fetch_manifest",
+			"Fetch a IIIF manifest by URL and return its label, summary, metadata, rights and canvas labels.",
+			map[string]interface{}{
+				"url": stringProp("The manifest URL."),
+			}, []string{"url"}, tb.fetchManifest),
+		newTool("list_collection",
+			"List the members (manifests and sub-collections) of a IIIF collection.",
+			map[string]interface{}{
+				"url": stringProp("The collection URL."),
+			}, []string{"url"}, tb.listCollection),
+		newTool("search_collection",
+			fmt.Sprintf("Fetch up to %d manifests in a collection and return those whose label, summary or metadata contain the query text (case-insensitive).", maxSearchManifests),
+			map[string]interface{}{
+				"url":   stringProp("The collection URL."),
+				"query": stringProp("Text to search for."),
+			}, []string{"url", "query"}, tb.searchCollection),
+		newTool("get_canvas",
+			"Get the label, dimensions and image service of one canvas in a manifest.",
+			map[string]interface{}{
+				"manifest_url": stringProp("The manifest URL."),
+				"index": map[string]interface{}{
+					"type":        "integer",
+					"description": "1-based position of the canvas in the manifest.",
+				},
+			}, []string{"manifest_url", "index"}, tb.getCanvas),
+		newTool("image_url",
+			"Build a IIIF Image API URL for an image service.",
+			map[string]interface{}{
+				"service":  stringProp("The Image API service base URL."),
+				"region":   stringProp("Region: full, square, x,y,w,h or pct:x,y,w,h. Defaults to full."),
+				"size":     stringProp("Size, e.g. max, 600, or !1024,1024. Defaults to !1024,1024."),
+				"rotation": map[string]interface{}{"type": "integer", "description": "Rotation in degrees."},
+				"quality":  stringProp("default, color, gray or bitonal."),
+				"format":   stringProp("jpg, png, ..."),
+			}, []string{"service"}, imageURL),
+	}
+	return tb
+}
+
+func newTool(name, description string, props map[string]interface{}, required []string,
+	run func(ctx context.Context, input json.RawMessage) (string, error),
+) ChatTool {
+	t := ChatTool{Run: run}
+	t.Spec.Name = name
+	t.Spec.Description = description
+	t.Spec.InputSchema.JSON = map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+	return t
+}
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// Config returns the toolConfig block describing every tool.
+func (tb *Toolbox) Config() *ToolConfig {
+	cfg := &ToolConfig{}
+	for _, t := range tb.Tools {
+		cfg.Tools = append(cfg.Tools, ToolDefinition{ToolSpec: t.Spec})
+	}
+	return cfg
+}
+
+// Execute runs each tool use and returns a user message carrying the results.
+// Tool failures are reported to the model rather than aborting the chat.
+func (tb *Toolbox) Execute(ctx context.Context, uses []ToolUse) Message {
+	msg := Message{Role: "user"}
+	for _, use := range uses {
+		result := &ToolResult{ToolUseID: use.ToolUseID, Status: "success"}
+
+		out, err := tb.run(ctx, use)
+		if err != nil {
+			out = "Error: " + err.Error()
+			result.Status = "error"
+		}
+		result.Content = []TextContent{{Text: out}}
+		msg.Content = append(msg.Content, ContentBlock{ToolResult: result})
+	}
+	return msg
+}
+
+func (tb *Toolbox) run(ctx context.Context, use ToolUse) (string, error) {
+	for _, t := range tb.Tools {
+		if t.Spec.Name == use.Name {
+			return t.Run(ctx, use.Input)
+		}
+	}
+	return "", fmt.Errorf("unknown tool %q", use.Name)
+}
+
+// RunCommand creates a Bubble Tea command that runs the tools asynchronously.
+func (tb *Toolbox) RunCommand(uses []ToolUse) tea.Cmd {
+	return func() tea.Msg {
+		return ToolResultsMsg{Message: tb.Execute(context.Background(), uses)}
+	}
+}
+
+type urlInput struct {
+	URL string `json:"url"`
+}
+
+func fetchResource(urlStr string) (*iiif.Resource, error) {
+	if urlStr == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	data, err := iiif.FetchDataSync(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return iiif.ParseResource(data)
+}
+
+func (tb *Toolbox) fetchManifest(_ context.Context, input json.RawMessage) (string, error) {
+	var in urlInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	res, err := fetchResource(in.URL)
+	if err != nil {
+		return "", err
+	}
+	return tb.Builder.Build([]*iiif.Resource{res}, nil), nil
+}
+
+func (tb *Toolbox) listCollection(_ context.Context, input json.RawMessage) (string, error) {
+	var in urlInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	res, err := fetchResource(in.URL)
+	if err != nil {
+		return "", err
+	}
+	if res.Type != "Collection" {
+		return "", fmt.Errorf("%s is a %s, not a Collection", in.URL, res.Type)
+	}
+	builder := tb.Builder
+	builder.Fields = []string{FieldLabel, FieldSummary, FieldItems}
+	return builder.Build([]*iiif.Resource{res}, res.Items), nil
+}
+
+func (tb *Toolbox) searchCollection(ctx context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		URL   string `json:"url"`
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(in.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	res, err := fetchResource(in.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var manifests []ui.Item
+	for _, item := range res.Items {
+		if item.ItemType == "Manifest" {
+			manifests = append(manifests, item)
+		}
+	}
+	total := len(manifests)
+	if total > maxSearchManifests {
+		manifests = manifests[:maxSearchManifests]
+	}
+
+	matches := make([]string, len(manifests))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	query := strings.ToLower(in.Query)
+	for i, item := range manifests {
+		wg.Add(1)
+		go func(i int, item ui.Item) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			m, err := fetchResource(item.URL)
+			if err != nil {
+				return
+			}
+			if field, ok := matchResource(m, query); ok {
+				matches[i] = fmt.Sprintf("- %s <%s> (matched %s)", m.Label, m.ID, field)
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Searched %d of %d manifests for %q.\n", len(manifests), total, in.Query)
+	found := 0
+	for _, line := range matches {
+		if line != "" {
+			sb.WriteString(line + "\n")
+			found++
+		}
+	}
+	if found == 0 {
+		sb.WriteString("No matches.\n")
+	}
+	return sb.String(), nil
+}
+
+// matchResource reports which field of res, if any, contains query.
+func matchResource(res *iiif.Resource, query string) (string, bool) {
+	if strings.Contains(strings.ToLower(res.Label), query) {
+		return "label", true
+	}
+	if strings.Contains(strings.ToLower(res.Summary), query) {
+		return "summary", true
+	}
+	for _, e := range res.Metadata {
+		if strings.Contains(strings.ToLower(e.Value), query) {
+			return e.Label, true
+		}
+	}
+	return "", false
+}
+
+func (tb *Toolbox) getCanvas(_ context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		ManifestURL string `json:"manifest_url"`
+		Index       int    `json:"index"`
+	}
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	res, err := fetchResource(in.ManifestURL)
+	if err != nil {
+		return "", err
+	}
+	if in.Index < 1 || in.Index > len(res.Canvases) {
+		return "", fmt.Errorf("index %d out of range; manifest has %d canvases", in.Index, len(res.Canvases))
+	}
+	c := res.Canvases[in.Index-1]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "id: %s\nlabel: %s\n", c.ID, c.Label)
+	if c.Width > 0 && c.Height > 0 {
+		fmt.Fprintf(&sb, "size: %dx%d\n", c.Width, c.Height)
+	}
+	if c.ImageService != "" {
+		fmt.Fprintf(&sb, "image service: %s\n", c.ImageService)
+	}
+	if c.ImageURL != "" {
+		fmt.Fprintf(&sb, "image: %s\n", c.ImageURL)
+	}
+	return sb.String(), nil
+}
+
+func imageURL(_ context.Context, input json.RawMessage) (string, error) {
+	var in struct {
+		Service string `json:"service"`
+		iiif.ImageRequest
+	}
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Service == "" {
+		return "", fmt.Errorf("service is required")
+	}
+	return iiif.ImageAPIURL(in.Service, in.ImageRequest), nil
+}
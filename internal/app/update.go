@@ -10,6 +10,7 @@ import (
 	"github.com/bmquinn/loam-iiif/internal/iiif"
 	"github.com/bmquinn/loam-iiif/internal/types"
 	"github.com/bmquinn/loam-iiif/internal/ui"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -21,16 +22,84 @@ const (
 	MinHeight = 24
 )
 
-// Update is the main update loop for the Bubble Tea program.
+// Update is the main update loop for the Bubble Tea program. After each
+// message it schedules the preview of the highlighted item, if it changed.
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	if preview := m.schedulePreview(); preview != nil {
+		cmd = tea.Batch(cmd, preview)
+	}
+	return model, cmd
+}
+
+func (m *Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// A fetched canvas image opens the chat panel, whether or not it is open.
+	if img, ok := msg.(CanvasImageMsg); ok {
+		return m, m.attachCanvasImage(img)
+	}
+
+	// Proposed metadata changes close the chat panel so they can be reviewed.
+	if enrich, ok := msg.(EnrichmentMsg); ok {
+		m.showEnrichment(enrich)
+		if m.Enrichment != nil {
+			m.ShowChat = false
+		}
+		return m, nil
+	}
+
+	// The mouse works on whatever is under the pointer, chat panel or not
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		return m, m.updateMouse(msg)
+	}
+
+	// Tabs can be renamed, opened, closed and switched from anywhere, and
+	// help opened anywhere without an overlay of its own
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if m.ShowHelp {
+			return m.updateHelp(msg)
+		}
+		if m.ShowTabPrompt {
+			return m.updateTabPrompt(msg)
+		}
+		if !m.typing(msg) {
+			if handled, cmd := m.updateTabKeys(msg); handled {
+				return m, cmd
+			}
+			if key.Matches(msg, m.Keys.ToggleMouse) {
+				return m, m.toggleMouse()
+			}
+			if key.Matches(msg, m.Keys.Help) && m.helpSections() != nil {
+				m.ShowHelp = true
+				return m, nil
+			}
+		}
+	}
+
+	// Filter results are computed in the background and arrive as messages
+	if matches, ok := msg.(list.FilterMatchesMsg); ok {
+		return m, m.updateFilterMatches(matches)
+	}
+
+	// Previews keep loading while the chat panel is open.
+	switch msg.(type) {
+	case previewTickMsg, PreviewMsg:
+		return m, m.updatePreview(msg)
+	}
+
 	// If the Chat panel is open, let the chat sub-update handle most inputs first.
 	if m.ShowChat {
 		newModel, subCmd := m.updateChat(msg)
 		return newModel, subCmd
 	}
 
+	// Chat replies keep arriving while the panel is closed, e.g. during tool use.
+	switch msg.(type) {
+	case ChatSetupMsg, ChatRetrievalMsg, ChatChunkMsg, ChatResponseMsg, ToolResultsMsg, ChatErrorMsg:
+		return m.updateChat(msg)
+	}
+
 	switch msg := msg.(type) {
 
 	case tea.WindowSizeMsg:
@@ -43,7 +112,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		contentWidth := msg.Width - 4
 		contentHeight := msg.Height - 2
 
-		m.List.SetDelegate(ui.NewItemDelegate(contentWidth - 4))
+		// The list shares the width with the preview in the split layout
+		listWidth := m.layoutSplit(contentWidth)
+		m.List.SetDelegate(ui.NewItemDelegate(listWidth-4, Theme))
 
 		textareaWidth := contentWidth
 		textareaHeight := 3
@@ -52,19 +123,21 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		const (
 			titleHeight  = 1
+			crumbHeight  = 1
 			statusHeight = 3
 			helpHeight   = 1
 			modelsHeight = 0 // Fixed height for foundation models
 			sectionGap   = 1
 		)
 
-		totalOverhead := titleHeight + textareaHeight + statusHeight + modelsHeight + helpHeight + (sectionGap * 4)
+		totalOverhead := titleHeight + crumbHeight + textareaHeight + statusHeight + modelsHeight + helpHeight + (sectionGap * 4)
 		listHeight := contentHeight - totalOverhead
 		if listHeight < 1 {
 			listHeight = 1
 		}
-		m.List.SetWidth(contentWidth - 2)
+		m.List.SetWidth(listWidth - 2)
 		m.List.SetHeight(listHeight - 2)
+		m.HistoryList.SetSize(contentWidth-2, listHeight-2)
 
 		// Update foundation models viewport size
 		m.ModelViewport.Width = contentWidth - 2
@@ -74,35 +147,95 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Also update chat sub-model to match new window size
 		m.Chat.Viewport.Width = contentWidth - 2
 		m.Chat.TextArea.SetWidth(contentWidth - 2)
+		m.Chat.Sessions.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+		m.Chat.Templates.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
 
 		return m, nil
 
 	case tea.KeyMsg:
-		key := msg.String()
+		k := m.Keys
+
+		// The semantic search box takes all keys while it is open
+		if m.ShowSearch {
+			return m.updateSearch(msg)
+		}
+
+		// So does the review of proposed metadata changes
+		if m.Enrichment != nil {
+			return m.updateEnrichment(msg)
+		}
+
+		// And the bookmark folder prompt
+		if m.ShowFolderPrompt {
+			return m.updateFolderPrompt(msg)
+		}
 
-		// Toggle chat with "c"
-		if key == "c" || key == "C" {
-			m.ShowChat = !m.ShowChat
-			if m.ShowChat {
+		// And the history overlay
+		if m.ShowHistory {
+			return m.updateHistory(msg)
+		}
+
+		// And the results list's filter box
+		if m.InList && !m.ShowDetail && m.List.FilterState() == list.Filtering {
+			var cmd tea.Cmd
+			m.List, cmd = m.List.Update(msg)
+			return m, cmd
+		}
+
+		// Keys that work everywhere, unless they type into the URL input
+		if !m.typing(msg) {
+			switch {
+			case key.Matches(msg, k.Quit):
+				return m, tea.Quit
+			case key.Matches(msg, k.History):
+				m.openHistory()
+				return m, nil
+			case key.Matches(msg, k.Bookmarks):
+				m.showBookmarks()
+				return m, nil
+			case key.Matches(msg, k.NavBack):
+				m.goBack()
+				return m, nil
+			case key.Matches(msg, k.NavForward):
+				m.goForward()
+				return m, nil
+			case key.Matches(msg, k.ToggleChat):
 				m.Status = "Opened chat panel."
-				// Focus the chat text area
-				m.Chat.TextArea.Focus()
-			} else {
-				m.Status = "Closed chat panel."
+				return m, m.openChat()
 			}
-			return m, nil
 		}
 
-		// If detail pane is open, check if user wants to close it with "esc"
+		// If detail pane is open, check if user wants to close it
 		if m.ShowDetail {
-			switch key {
-			case "ctrl+c":
-				return m, tea.Quit
-			case "esc":
+			switch {
+			case key.Matches(msg, k.Back):
 				// Close the detail pane
 				m.ShowDetail = false
+				m.DetailResource = nil
+				m.DetailData = nil
+				m.refreshChatContext()
 				m.Status = "Closed detail pane."
 				return m, nil
+			case key.Matches(msg, k.PrevCanvas, k.NextCanvas):
+				// Step through the manifest's canvases
+				if res := m.DetailResource; res != nil && len(res.Canvases) > 0 {
+					if key.Matches(msg, k.PrevCanvas) {
+						m.CanvasIndex = (m.CanvasIndex - 1 + len(res.Canvases)) % len(res.Canvases)
+					} else {
+						m.CanvasIndex = (m.CanvasIndex + 1) % len(res.Canvases)
+					}
+				}
+				return m, nil
+			case key.Matches(msg, k.AskImage):
+				return m, m.askAboutCanvas()
+			case key.Matches(msg, k.Enrich):
+				return m, m.startEnrichment()
+			case key.Matches(msg, k.Bookmark):
+				m.bookmarkDetail(false)
+				return m, nil
+			case key.Matches(msg, k.BookmarkCanvas):
+				m.bookmarkDetail(true)
+				return m, nil
 			}
 			// If the detail pane is open, ignore other keys
 			return m, nil
@@ -110,20 +243,18 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// If we are NOT in the list, handle text input or switching
 		if !m.InList {
-			switch key {
-			case "ctrl+c":
-				return m, tea.Quit
-
-			case "tab":
+			switch {
+			case key.Matches(msg, k.SwitchFocus):
 				m.TextArea.Blur()
 				m.InList = true
 				m.Status = "Ready"
 				if len(m.List.Items()) > 0 {
 					m.List.Select(0)
+					m.skipHeaders(false)
 				}
 				return m, nil
 
-			case "enter":
+			case key.Matches(msg, k.Load):
 				urlInput := m.TextArea.Value()
 				if urlInput != "" {
 					parsedURL, err := url.ParseRequestURI(urlInput)
@@ -135,12 +266,14 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.Status = "URL must include http:// or https://"
 						return m, nil
 					}
-					m.Status = "Fetching data..."
-					m.Loading = true
-					cmds = append(cmds, iiif.FetchData(urlInput), m.Spinner.Tick)
-					return m, tea.Batch(cmds...)
+					return m, m.loadURL(urlInput)
 				}
+
+			case key.Matches(msg, k.RecallOlder, k.RecallNewer):
+				m.recallHistory(key.Matches(msg, k.RecallOlder))
+				return m, nil
 			}
+			m.historyCursor = -1
 			var cmd tea.Cmd
 			m.TextArea, cmd = m.TextArea.Update(msg)
 			if cmd != nil {
@@ -150,64 +283,58 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// If we ARE in the list:
-		switch key {
-		case "ctrl+c":
-			// Only Ctrl+C quits.
-			return m, tea.Quit
-
-		case "esc":
+		if handled, cmd := m.updateBookmarkKeys(msg); handled {
+			return m, cmd
+		}
+		switch {
+		case key.Matches(msg, k.Back):
+			// Clear an applied filter before going back
+			if m.List.FilterState() != list.Unfiltered {
+				m.List.ResetFilter()
+				m.Status = "Cleared filter."
+				return m, nil
+			}
 			// Instead of quitting, let's go "back" if possible.
 			if len(m.PrevItemsStack) > 0 {
-				// Pop the last item slice from the stack
-				lastIndex := len(m.PrevItemsStack) - 1
-				prevItems := m.PrevItemsStack[lastIndex]
-				m.PrevItemsStack = m.PrevItemsStack[:lastIndex]
-
-				// Restore the previous list
-				m.List.SetItems(prevItems)
-				m.Status = "Went back to previous list."
+				m.jumpTo(len(m.PrevItemsStack) - 1)
 			} else {
 				m.Status = "No previous items to go back to."
 			}
 			return m, nil
 
-		case "tab":
+		case key.Matches(msg, k.JumpLevel):
+			// Jump to an ancestor in the breadcrumb; 1 is the root
+			m.jumpTo(keyIndex(k.JumpLevel, msg))
+			return m, nil
+
+		case key.Matches(msg, k.Bookmark):
+			m.bookmarkSelected()
+			return m, nil
+
+		case key.Matches(msg, k.Sort):
+			return m, m.cycleSort()
+
+		case key.Matches(msg, k.Group):
+			return m, m.cycleGroup()
+
+		case key.Matches(msg, k.SwitchFocus):
 			m.TextArea.Focus()
 			m.InList = false
 			m.Status = "Ready"
 			return m, nil
 
-		case "up", "down", "k", "j":
+		case key.Matches(msg, m.List.KeyMap.CursorUp, m.List.KeyMap.CursorDown):
 			if m.Status == "Opened in browser" {
 				m.Status = "Ready"
 			}
 
-		case "enter":
-			// Show detail or fetch nested collection
-			if item, ok := m.List.SelectedItem().(ui.Item); ok {
-				if strings.EqualFold(item.ItemType, "collection") {
-					// Push the CURRENT list onto the stack
-					currentItems := m.List.Items()
-					m.PrevItemsStack = append(m.PrevItemsStack, currentItems)
-
-					// Fetch the new collection
-					m.Status = "Fetching nested collection..."
-					m.Loading = true
-					return m, tea.Batch(
-						iiif.FetchData(item.URL),
-						m.Spinner.Tick,
-					)
+		case key.Matches(msg, k.Open):
+			return m, m.openSelected()
 
-				} else {
-					// It's a manifest (or something else)
-					m.SelectedItem = item
-					m.ShowDetail = true
-					m.Status = fmt.Sprintf("Viewing detail: %s", item.Title)
-				}
-			}
-			return m, nil
+		case key.Matches(msg, k.Search):
+			return m, m.openSearch()
 
-		case "o", "O":
+		case key.Matches(msg, k.OpenBrowser):
 			if item, ok := m.List.SelectedItem().(ui.Item); ok && item.URL != "Error" {
 				if err := iiif.OpenURL(item.URL); err != nil {
 					m.Status = "Failed to open URL"
@@ -220,6 +347,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		var cmd tea.Cmd
 		m.List, cmd = m.List.Update(msg)
+		m.skipHeaders(key.Matches(msg, m.List.KeyMap.CursorUp))
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
@@ -227,8 +355,15 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case types.FetchDataMsg:
 		// We got new data back from the IIIF API
 		newItems := iiif.ParseData(msg)
+
+		// A top-level fetch starts a new path that back returns from
+		if m.pendingLevel == nil && m.Level.Label != "" {
+			m.Back = append(m.Back, m.currentNavState())
+			m.Forward = nil
+		}
 		var listItems []list.Item
 		for _, item := range newItems {
+			item.Metadata = m.ItemMetadata[item.URL]
 			listItems = append(listItems, item)
 		}
 
@@ -239,18 +374,55 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.Status = fmt.Sprintf("Fetched %d items", len(newItems))
 		m.Loading = false
 
-		// Extract relevant context from newItems and store it
-		// For example, concatenate titles or descriptions
-		var contextBuilder strings.Builder
-		for _, item := range newItems {
-			contextBuilder.WriteString(fmt.Sprintf("Title: %s\nURL: %s\n\n", item.Title, item.URL))
+		// Keep the full resource so chat context can include its metadata
+		if res, err := iiif.ParseResource(msg); err == nil {
+			m.Resource = res
+			m.setLevel(res)
+			m.recordHistory(m.fetchURL, res)
+			if res.Type == "Collection" {
+				m.loadIndex(res.ID)
+			}
 		}
-		m.Chat.Context = contextBuilder.String()
 
+		// Sort and group the list as it was last time
+		cmd := m.arrangeList()
+		m.refreshChatContext()
+
+		return m, cmd
+
+	case types.FetchDetailMsg:
+		// A slow response for an item that is no longer open only adds to
+		// the metadata cache
+		current := m.ShowDetail && msg.URL == m.SelectedItem.URL
+		if current {
+			m.Loading = false
+		}
+		res, err := iiif.ParseResource(msg.Body)
+		if err != nil {
+			if current {
+				m.Status = "Error: " + err.Error()
+			}
+			return m, nil
+		}
+		m.cacheMetadata(res)
+		if current {
+			m.DetailResource = res
+			m.DetailData = msg.Body
+			m.selectPendingCanvas()
+			m.refreshChatContext()
+			m.Status = fmt.Sprintf("Viewing detail: %s (%d canvases)", m.SelectedItem.Title, len(res.Canvases))
+		}
+		return m, nil
+
+	case SemanticSearchMsg:
+		m.showSearchResults(msg)
+		m.refreshChatContext()
 		return m, nil
 
 	case types.ErrMsg:
 		m.Status = "Error: " + msg.Error.Error()
+		m.pendingLevel = nil
+		m.pendingCanvas = ""
 		m.Loading = false
 		return m, nil
 
@@ -286,13 +458,62 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// openSelected opens the highlighted item: nested collections are fetched
+// and anything else is shown in the detail pane. In the bookmarks, folders
+// are listed and canvases shown in their manifest.
+func (m *Model) openSelected() tea.Cmd {
+	item, ok := m.List.SelectedItem().(ui.Item)
+	if !ok {
+		return nil
+	}
+	if _, ok := m.bookmarksLevel(); ok {
+		switch item.ItemType {
+		case "Folder":
+			m.openBookmarkFolder(strings.TrimPrefix(item.URL, bookmarksURL))
+			return nil
+		case "Canvas":
+			return m.openCanvasBookmark(item)
+		}
+	}
+
+	if strings.EqualFold(item.ItemType, "collection") {
+		// Push the CURRENT list onto the stack once the collection arrives
+		current := m.currentLevel()
+		m.pendingLevel = &current
+		m.fetchURL = item.URL
+
+		// Fetch the new collection
+		m.Status = "Fetching nested collection..."
+		m.Loading = true
+		return tea.Batch(
+			iiif.FetchData(item.URL),
+			m.Spinner.Tick,
+		)
+	}
+
+	// It's a manifest (or something else)
+	m.SelectedItem = item
+	m.ShowDetail = true
+	m.DetailResource = nil
+	m.DetailData = nil
+	m.CanvasIndex = 0
+	m.Status = fmt.Sprintf("Viewing detail: %s", item.Title)
+
+	// Load the full manifest so chat can see its metadata and canvases
+	if strings.EqualFold(item.ItemType, "manifest") {
+		m.Loading = true
+		return tea.Batch(iiif.FetchDetail(item.URL), m.Spinner.Tick)
+	}
+	return nil
+}
+
 // Init sets up any initial commands for the Bubble Tea program.
 func (m *Model) Init() tea.Cmd {
-	return tea.Batch(
-		textarea.Blink,
-		m.Spinner.Tick,
-		GetModels(), // Fetch foundation models at startup
-	)
+	cmds := []tea.Cmd{textarea.Blink, m.Spinner.Tick}
+	if m.startURL != "" {
+		cmds = append(cmds, m.loadURL(m.startURL))
+	}
+	return tea.Batch(cmds...)
 }
 
 // updateChat handles messages for the chat panel when it's open.
@@ -303,6 +524,18 @@ func (m *Model) updateChat(msg tea.Msg) (tea.Model, tea.Cmd) {
 		chatCmd tea.Cmd
 	)
 
+	// The session browser and template palette take all keys while open
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case m.Chat.ShowSessions:
+			return m.updateSessionBrowser(key)
+		case m.Chat.ShowTemplates:
+			return m.updateTemplatePalette(key)
+		case m.Chat.ShowSettings:
+			return m.updateSettings(key)
+		}
+	}
+
 	// Update text area and viewport
 	m.Chat.TextArea, tiCmd = m.Chat.TextArea.Update(msg)
 	m.Chat.Viewport, vpCmd = m.Chat.Viewport.Update(msg)
@@ -313,63 +546,319 @@ func (m *Model) updateChat(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.Chat.Viewport.Width = msg.Width - 4
 		m.Chat.Viewport.Height = msg.Height / 3
 		m.Chat.TextArea.SetWidth(msg.Width - 4)
+		m.Chat.Sessions.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
+		m.Chat.Templates.SetSize(m.Chat.Viewport.Width, m.Chat.Viewport.Height)
 
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEsc, tea.KeyCtrlC:
+		k := m.Keys
+		switch {
+		case key.Matches(msg, k.CloseChat):
 			// Close chat if user presses Esc or Ctrl+C
 			m.ShowChat = false
 			m.Status = "Closed chat panel."
 			return m, nil
 
-		case tea.KeyEnter:
+		case key.Matches(msg, k.ChatContext):
+			// Toggle between the conversation and the context sent with it
+			m.Chat.ShowContext = !m.Chat.ShowContext
+			m.renderChatViewport()
+			return m, nil
+
+		case key.Matches(msg, k.ChatSessions):
+			m.openSessionBrowser()
+			return m, nil
+
+		case key.Matches(msg, k.NewSession):
+			if m.Chat.InFlight {
+				m.Status = "Wait for the reply before starting a new session."
+				return m, nil
+			}
+			m.newSession()
+			m.Status = "Started a new chat session."
+			return m, nil
+
+		case key.Matches(msg, k.SaveSession):
+			m.ensureSession()
+			m.saveSession()
+			m.Status = "Saved chat session " + m.Chat.Session.ID
+			return m, nil
+
+		case key.Matches(msg, k.Send):
 			// On Enter, send the message to Bedrock
 			userInput := strings.TrimSpace(m.Chat.TextArea.Value())
 			if userInput == "" {
-				// Ignore empty messages
+				// With nothing typed, Enter follows the selected citation
+				if m.Chat.Cited >= 0 && m.Chat.Cited < len(m.Chat.Citations) {
+					m.followCitation()
+				}
+				return m, nil
+			}
+			if m.Chat.InFlight {
+				m.Status = "Wait for the reply before sending another message."
 				return m, nil
 			}
-
-			// Append user's message
-			userMessage := m.Chat.SenderStyle.Render("You: ") + userInput
-			m.Chat.Messages = append(m.Chat.Messages, userMessage)
-
-			// Update chat viewport
-			m.Chat.Viewport.SetContent(strings.Join(m.Chat.Messages, "\n\n"))
-			m.Chat.Viewport.GotoBottom()
 
 			// Clear the text area
 			m.Chat.TextArea.Reset()
 
-			// Send the message to Bedrock with context
-			chatCmd = SendChat(userInput, m.Chat.Context)
+			chatCmd = m.sendPrompt(userInput, userInput)
 			return m, tea.Batch(tiCmd, vpCmd, chatCmd)
+
+		case key.Matches(msg, k.Templates):
+			m.openTemplatePalette()
+			return m, nil
+
+		case key.Matches(msg, k.Citation):
+			m.cycleCitation()
+			return m, nil
+
+		case key.Matches(msg, k.Settings):
+			return m, m.openSettings()
+
+		case key.Matches(msg, k.RetrySetup):
+			// Retry setup, e.g. after logging in again
+			resetChatService()
+			m.Status = "Checking chat setup..."
+			return m, m.checkChatSetup()
 		}
 
+	case ChatSetupMsg:
+		m.Chat.CheckingSetup = false
+		m.Chat.Setup = &msg
+		if msg.Ready {
+			m.AvailableModels = msg.Models
+			m.ModelViewport.SetContent("Available Foundation Models:\n\n- " + strings.Join(msg.Models, "\n- "))
+		}
+		return m, nil
+
+	case ChatRetrievalMsg:
+		if msg.Err != nil {
+			m.Chat.Messages = append(m.Chat.Messages,
+				ToolStyle.Render("Semantic retrieval failed: "+msg.Err.Error()))
+		} else if len(msg.Hits) > 0 {
+			m.Chat.Messages = append(m.Chat.Messages,
+				ToolStyle.Render(fmt.Sprintf("Retrieved %d related manifests from the index.", len(msg.Hits))))
+		}
+		m.Chat.Hits = msg.Hits
+		m.refreshChatContext()
+		m.renderChatViewport()
+		return m, m.sendChat()
+
+	case ChatChunkMsg:
+		m.Chat.Streaming += msg.Text
+		m.renderChatViewport()
+		return m, msg.Next()
+
 	case ChatResponseMsg:
+		m.Chat.Streaming = ""
+		m.Chat.History = append(m.Chat.History, msg.Message)
+		m.countUsage(msg)
+
 		// Append the assistant's response to messages
-		assistantResponse := strings.TrimSpace(msg.Response)
+		assistantResponse := strings.TrimSpace(msg.Message.Text())
 		if assistantResponse != "" {
-			assistantMessage := AssistantStyle.Render("Assistant: ") + assistantResponse
-			m.Chat.Messages = append(m.Chat.Messages, assistantMessage)
-			// log.Printf("Assistant message appended: %s", assistantResponse)
+			m.Chat.Messages = append(m.Chat.Messages, assistantLine(assistantResponse, ""))
+			m.setCitations(assistantResponse)
+		}
 
-			// Update chat viewport
-			m.Chat.Viewport.SetContent(strings.Join(m.Chat.Messages, "\n\n"))
-			m.Chat.Viewport.GotoBottom()
+		uses := msg.Message.ToolUses()
+		for _, use := range uses {
+			m.Chat.Messages = append(m.Chat.Messages, toolUseLine(use))
+		}
+
+		if msg.StopReason == "tool_use" && len(uses) > 0 {
+			if m.Chat.Steps >= m.MaxToolSteps {
+				// Roll back the whole turn, as for an error: dropping just
+				// the unanswered tool request would leave the history
+				// ending on the last tool results, a user message
+				m.Chat.History = m.Chat.History[:m.Chat.turnStart]
+				m.Chat.InFlight = false
+				m.Chat.Messages = append(m.Chat.Messages, m.Chat.SenderStyle.Render("Error: ")+
+					fmt.Sprintf("stopped after %d tool steps", m.MaxToolSteps), m.turnUsageLine())
+				m.saveSession()
+				m.renderChatViewport()
+				return m, nil
+			}
+			m.Chat.Steps++
+			m.renderChatViewport()
+			return m, RunTools(uses)
 		}
+
+		m.Chat.InFlight = false
+		m.Chat.Messages = append(m.Chat.Messages, m.turnUsageLine())
+
+		// Update chat viewport
+		m.saveSession()
+		m.renderChatViewport()
 		return m, nil
 
+	case ToolResultsMsg:
+		m.Chat.History = append(m.Chat.History, msg.Message)
+		for _, block := range msg.Message.Content {
+			if block.ToolResult == nil || len(block.ToolResult.Content) == 0 {
+				continue
+			}
+			m.Chat.Messages = append(m.Chat.Messages, toolResultLine(block.ToolResult.Content[0].Text))
+		}
+		m.renderChatViewport()
+		return m, m.sendChat()
+
 	case ChatErrorMsg:
+		m.Chat.Streaming = ""
+		m.Chat.InFlight = false
+		if setupProblem(msg.Error) {
+			problem, hint := DiagnoseChatError(msg.Error)
+			m.Chat.Setup = &ChatSetupMsg{Problem: problem, Hint: hint, Err: msg.Error}
+		}
+
+		// Roll back the failed turn so the next prompt starts from a valid history
+		if m.Chat.turnStart < len(m.Chat.History) {
+			m.Chat.History = m.Chat.History[:m.Chat.turnStart]
+		}
+
 		// Append the error message to messages
 		errorMessage := m.Chat.SenderStyle.Render("Error: ") + msg.Error.Error()
 		m.Chat.Messages = append(m.Chat.Messages, errorMessage)
 
 		// Update chat viewport
-		m.Chat.Viewport.SetContent(strings.Join(m.Chat.Messages, "\n\n"))
-		m.Chat.Viewport.GotoBottom()
+		m.saveSession()
+		m.renderChatViewport()
 		return m, nil
 	}
 
 	return m, tea.Batch(tiCmd, vpCmd, chatCmd)
 }
+
+// refreshChatContext rebuilds the chat context from the fetched resource, the
+// manifest open in the detail pane and the items in the current list.
+func (m *Model) refreshChatContext() {
+	resources := []*iiif.Resource{m.Resource}
+	if m.ShowDetail && m.DetailResource != nil {
+		resources = append(resources, m.DetailResource)
+	}
+
+	var items []ui.Item
+	for _, li := range m.List.Items() {
+		if item, ok := li.(ui.Item); ok {
+			items = append(items, item)
+		}
+	}
+
+	m.Chat.Context = m.ContextBuilder.BuildWithHits(resources, items, m.Chat.Hits)
+	m.Chat.References = m.ContextBuilder.References(resources, items, m.Chat.Hits)
+	if m.Chat.ShowContext {
+		m.renderChatViewport()
+	}
+}
+
+// renderChatViewport shows either the conversation or the current context.
+func (m *Model) renderChatViewport() {
+	if m.Chat.ShowContext {
+		header := fmt.Sprintf("Context (~%d tokens", EstimateTokens(m.Chat.Context))
+		if m.ContextBuilder.TokenBudget > 0 {
+			header += fmt.Sprintf(" of %d budget", m.ContextBuilder.TokenBudget)
+		}
+		header += ") — " + m.Keys.ChatContext.Help().Key + " to return"
+		body := m.Chat.Context
+		if body == "" {
+			body = "No context yet. Fetch a collection or manifest first."
+		}
+		m.Chat.Viewport.SetContent(HelpStyle.Render(header) + "\n\n" + body)
+		m.Chat.Viewport.GotoTop()
+		return
+	}
+	if len(m.Chat.Messages) == 0 {
+		m.Chat.Viewport.SetContent(m.chatWelcome())
+		return
+	}
+	lines := m.Chat.Messages
+	if m.Chat.Streaming != "" {
+		lines = append(lines[:len(lines):len(lines)], AssistantStyle.Render("Assistant: ")+m.Chat.Streaming)
+	}
+	m.Chat.Viewport.SetContent(strings.Join(lines, "\n\n"))
+	m.Chat.Viewport.GotoBottom()
+}
+
+// summarizeToolResult shortens a tool result to its first line for display.
+func summarizeToolResult(result string) string {
+	result = strings.TrimSpace(result)
+	lines := strings.Count(result, "\n") + 1
+	if i := strings.Index(result, "\n"); i >= 0 {
+		result = result[:i]
+	}
+	if len([]rune(result)) > 120 {
+		result = string([]rune(result)[:117]) + "..."
+	}
+	if lines > 1 {
+		result += fmt.Sprintf(" (+%d lines)", lines-1)
+	}
+	return result
+}
+
+// askAboutCanvas fetches the highlighted canvas image at a size suited to
+// the chat model, so it can be attached to the next chat message.
+func (m *Model) askAboutCanvas() tea.Cmd {
+	res := m.DetailResource
+	if res == nil || len(res.Canvases) == 0 {
+		m.Status = "No canvases loaded for this item."
+		return nil
+	}
+	modelID, caps := ChatCapabilities()
+	if !caps.ImageInput {
+		m.Status = fmt.Sprintf("Model %q does not accept image input.", modelID)
+		return nil
+	}
+
+	canvas := res.Canvases[m.CanvasIndex]
+	m.Status = "Fetching canvas image..."
+	m.Loading = true
+	return tea.Batch(FetchCanvasImage(canvas, caps.MaxImageEdge), m.Spinner.Tick)
+}
+
+// attachCanvasImage opens the chat panel with img ready to send with the next message.
+func (m *Model) attachCanvasImage(img CanvasImageMsg) tea.Cmd {
+	m.Loading = false
+	m.Chat.PendingImage = &img
+	m.Chat.Messages = append(m.Chat.Messages,
+		ToolStyle.Render(fmt.Sprintf("[image attached: %s] Ask a question about it.", img.Label)))
+	m.Chat.ShowContext = false
+	m.renderChatViewport()
+	m.Status = "Opened chat with canvas image."
+	return m.openChat()
+}
+
+// sendPrompt starts a new turn with prompt, showing display in the chat
+// viewport, and returns the command that sends it to the model.
+func (m *Model) sendPrompt(display, prompt string) tea.Cmd {
+	// Append user's message
+	userMessage := m.Chat.SenderStyle.Render("You: ") + display
+	m.Chat.Messages = append(m.Chat.Messages, userMessage)
+
+	// Update chat viewport
+	m.Chat.ShowContext = false
+	m.renderChatViewport()
+
+	// Start a new turn in the conversation history
+	content := []ContentBlock{{Text: prompt}}
+	if img := m.Chat.PendingImage; img != nil {
+		content = append([]ContentBlock{{Image: &img.Image}}, content...)
+		m.Chat.PendingImage = nil
+	}
+	m.ensureSession()
+	m.Chat.turnStart = len(m.Chat.History)
+	m.Chat.InFlight = true
+	m.Chat.Steps = 0
+	m.Chat.TurnUsage = UsageTotals{}
+	m.Chat.History = append(m.Chat.History, Message{
+		Role:    "user",
+		Content: content,
+	})
+
+	// Look up related manifests in the semantic index first, if there is one
+	if m.Index != nil {
+		return RetrieveForChat(m.Index, m.EmbeddingsConfig, prompt)
+	}
+
+	// Send the conversation to Bedrock with context
+	return m.sendChat()
+}
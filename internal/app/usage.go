@@ -0,0 +1,159 @@
+// File: /loam/internal/app/usage.go
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/bmquinn/loam-iiif/internal/index"
+	"github.com/bmquinn/loam-iiif/internal/usage"
+)
+
+// Usage is the token count of one model request, as reported in the usage
+// block of Bedrock responses.
+type Usage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+
+	// Estimated is set when the provider reported no usage and the counts
+	// were estimated from the text sent and received.
+	Estimated bool `json:"-"`
+}
+
+// UsageTotals sums the usage of several requests, e.g. one chat turn or a
+// whole session.
+type UsageTotals struct {
+	Requests     int           `json:"requests"`
+	InputTokens  int           `json:"input_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+	Estimated    bool          `json:"estimated,omitempty"`
+	Latency      time.Duration `json:"-"`
+}
+
+// Add counts one request.
+func (t *UsageTotals) Add(u Usage, latency time.Duration) {
+	t.Requests++
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.Estimated = t.Estimated || u.Estimated
+	t.Latency += latency
+}
+
+// String summarizes the totals, e.g. "1234 in · 210 out tokens · 2 requests · 1.8s".
+// Estimated counts are marked with "~".
+func (t UsageTotals) String() string {
+	approx := ""
+	if t.Estimated {
+		approx = "~"
+	}
+	s := fmt.Sprintf("%s%d in · %s%d out tokens", approx, t.InputTokens, approx, t.OutputTokens)
+	if t.Requests > 1 {
+		s += fmt.Sprintf(" · %d requests", t.Requests)
+	}
+	if t.Latency > 0 {
+		s += fmt.Sprintf(" · %.1fs", t.Latency.Seconds())
+	}
+	return s
+}
+
+// estimateUsage approximates the token counts of a request and its reply
+// from their text.
+func estimateUsage(req ChatRequest, reply Message) *Usage {
+	u := &Usage{Estimated: true}
+	for _, s := range req.System {
+		u.InputTokens += EstimateTokens(s.Text)
+	}
+	for _, msg := range req.Messages {
+		u.InputTokens += estimateMessageTokens(msg)
+	}
+	u.OutputTokens = estimateMessageTokens(reply)
+	return u
+}
+
+func estimateMessageTokens(msg Message) int {
+	n := 0
+	for _, block := range msg.Content {
+		n += EstimateTokens(block.Text)
+		if block.ToolUse != nil {
+			n += EstimateTokens(string(block.ToolUse.Input))
+		}
+		if block.ToolResult != nil {
+			for _, c := range block.ToolResult.Content {
+				n += EstimateTokens(c.Text)
+			}
+		}
+	}
+	return n
+}
+
+// recordUsage appends a response's usage to the usage log. Failing to record
+// usage never fails the request.
+func (cs *ChatService) recordUsage(response *ChatResponse) {
+	if cs.UsageLog == nil {
+		return
+	}
+	_ = cs.UsageLog.Append(usage.Record{
+		Time:         time.Now().UTC(),
+		Model:        cs.Provider.ModelID(),
+		InputTokens:  response.Usage.InputTokens,
+		OutputTokens: response.Usage.OutputTokens,
+		Estimated:    response.Usage.Estimated,
+		LatencyMS:    response.Latency.Milliseconds(),
+	})
+}
+
+// usageLog returns the usage log, or nil if usage recording is disabled.
+func usageLog(cfg config.Config) *usage.Log {
+	if cfg.Usage.Disabled {
+		return nil
+	}
+	path, err := usage.DefaultPath()
+	if err != nil {
+		return nil
+	}
+	return &usage.Log{Path: path}
+}
+
+// embeddingUsage returns a callback recording each request of an embedder
+// using model in the usage log, or nil if usage recording is disabled.
+func embeddingUsage(cfg config.Config, model string) func(index.RequestUsage) {
+	log := usageLog(cfg)
+	if log == nil {
+		return nil
+	}
+	return func(u index.RequestUsage) {
+		_ = log.Append(usage.Record{
+			Time:        time.Now().UTC(),
+			Model:       model,
+			InputTokens: u.InputTokens,
+			Estimated:   u.Estimated,
+			LatencyMS:   u.Latency.Milliseconds(),
+		})
+	}
+}
+
+// countUsage adds a reply's usage to the current turn and the session.
+func (m *Model) countUsage(msg ChatResponseMsg) {
+	m.Chat.TurnUsage.Add(msg.Usage, msg.Latency)
+	m.ensureSession()
+	if m.Chat.Session.Usage == nil {
+		m.Chat.Session.Usage = &UsageTotals{}
+	}
+	m.Chat.Session.Usage.Add(msg.Usage, msg.Latency)
+}
+
+// turnUsageLine shows the usage of the turn that just finished.
+func (m *Model) turnUsageLine() string {
+	return ToolStyle.Render("↳ " + m.Chat.TurnUsage.String())
+}
+
+// sessionUsageLine shows the session's total usage at the top of the chat panel.
+func (m *Model) sessionUsageLine() string {
+	s := m.Chat.Session
+	if s == nil || s.Usage == nil || s.Usage.Requests == 0 {
+		return ""
+	}
+	return ToolStyle.Render("Session: " + s.Usage.String())
+}
@@ -5,12 +5,24 @@ package app
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// View renders the screen, recording the zones the mouse can click.
 func (m *Model) View() string {
 	var sections []string
 
+	// The zones marked while rendering a section are placed where it ends
+	// up, inside the padding around the screen
+	m.zones = m.zones[:0]
+	top := 1
+	add := func(section string, start int) {
+		m.place(start, 2, top)
+		sections = append(sections, section)
+		top += lipgloss.Height(section)
+	}
+
 	// Title
 	var title string
 	if !m.InList {
@@ -28,20 +40,27 @@ func (m *Model) View() string {
 	}
 
 	// Construct top sections
-	sections = append(sections,
-		title,
-		textAreaView,
-	)
+	start := len(m.zones)
+	if tabs := m.tabBar(); tabs != "" {
+		m.place(start, lipgloss.Width(title)+2, 0)
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, "  ", tabs)
+	}
+	add(title, start)
+	start = len(m.zones)
+	if crumbs := m.breadcrumbView(); crumbs != "" {
+		add(crumbs, start)
+	}
+	start = len(m.zones)
+	m.mark(zone{ID: zoneInput, W: lipgloss.Width(textAreaView), H: lipgloss.Height(textAreaView)})
+	add(textAreaView, start)
 
 	// Status
 	statusContent := m.Status
 	if m.Loading {
 		statusContent = fmt.Sprintf("%s %s", m.Spinner.View(), m.Status)
 	}
-	sections = append(sections,
-		TitleStyle.Render("Status"),
-		BorderStyle.Render(statusContent),
-	)
+	add(TitleStyle.Render("Status"), len(m.zones))
+	add(BorderStyle.Render(statusContent), len(m.zones))
 
 	// Add Foundation Models section using ModelViewport
 	// foundationModelsSection := TitleStyle.Render("Foundation Models")
@@ -51,19 +70,39 @@ func (m *Model) View() string {
 	// 	BorderStyle.Render(modelsContent),
 	// )
 
+	// Semantic search box
+	if m.ShowSearch {
+		add(TitleStyle.Render("Semantic Search"), len(m.zones))
+		add(FocusedBorderStyle.Render(m.Search.View()), len(m.zones))
+	}
+
+	// Tab name prompt
+	if m.ShowTabPrompt {
+		add(TitleStyle.Render("Rename Tab"), len(m.zones))
+		add(FocusedBorderStyle.Render(m.TabPrompt.View()), len(m.zones))
+	}
+
+	// Bookmark folder prompt
+	if m.ShowFolderPrompt {
+		add(TitleStyle.Render("Bookmark Folder"), len(m.zones))
+		add(FocusedBorderStyle.Render(m.FolderPrompt.View()), len(m.zones))
+	}
+
 	// Main Section (Results or Detail)
+	start = len(m.zones)
 	mainSection := m.renderMainSection()
-	sections = append(sections, mainSection)
+	add(mainSection, start)
 
 	// If the chat panel is open, render the chat at the bottom
 	if m.ShowChat {
+		start = len(m.zones)
 		chatSection := m.renderChatSection()
-		sections = append(sections, chatSection)
+		add(chatSection, start)
 	}
 
-	// Footer help
-	helpMsg := "Tab: Switch Focus | Enter: Open Detail | O: Open URL in browser | Esc: Close Detail/Back | c: Toggle Chat"
-	sections = append(sections, HelpStyle.Render(helpMsg))
+	// Footer help for where the focus is
+	m.Help.Width = m.Width
+	add(m.Help.ShortHelpView(m.shortHelp()), len(m.zones))
 
 	// Join all sections vertically
 	mainContent := lipgloss.JoinVertical(lipgloss.Left, sections...)
@@ -72,6 +111,26 @@ func (m *Model) View() string {
 
 // renderMainSection handles either the detail view or the list view.
 func (m *Model) renderMainSection() string {
+	if m.Enrichment != nil {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			TitleStyle.Render("Metadata Enrichment"),
+			FocusedBorderStyle.Render(m.enrichmentView()),
+		)
+	}
+
+	if m.ShowHelp {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			TitleStyle.Render("Key Bindings"),
+			FocusedBorderStyle.Render(m.helpView()),
+		)
+	}
+
+	if m.ShowHistory {
+		m.mark(zone{ID: zoneHistory, X: 2, Y: 1, W: m.HistoryList.Width(), H: m.HistoryList.Height()})
+		return FocusedBorderStyle.Render(m.HistoryList.View()) + "\n" +
+			HelpStyle.Render("Enter: Open | /: Search | d: Remove | Esc: Close")
+	}
+
 	if m.ShowDetail {
 		// Show selected record detail
 		detailString := fmt.Sprintf(
@@ -79,6 +138,23 @@ func (m *Model) renderMainSection() string {
 			m.SelectedItem.Title,
 			m.SelectedItem.URL,
 		)
+		if res := m.DetailResource; res != nil {
+			if res.Summary != "" {
+				detailString += "\n\n" + lipgloss.NewStyle().Width(m.Width-4).Render(res.Summary)
+			}
+			detailString += fmt.Sprintf("\n\nCanvases: %d | Metadata fields: %d", len(res.Canvases), len(res.Metadata))
+			if len(res.Canvases) > 0 {
+				c := res.Canvases[m.CanvasIndex]
+				detailString += fmt.Sprintf("\n\nCanvas %d/%d: %s", m.CanvasIndex+1, len(res.Canvases), c.Label)
+				if c.Width > 0 && c.Height > 0 {
+					detailString += fmt.Sprintf(" (%dx%d)", c.Width, c.Height)
+				}
+				detailString += "\n" + m.Help.ShortHelpView([]key.Binding{m.Keys.PrevCanvas, m.Keys.NextCanvas, m.Keys.AskImage})
+			}
+			detailString += "\n" + m.Help.ShortHelpView([]key.Binding{m.Keys.Enrich,
+				withHelp(m.Keys.Bookmark, "bookmark manifest"), m.Keys.BookmarkCanvas})
+		}
+		m.textZone(zoneDetail, 2, 2, detailString)
 		return lipgloss.JoinVertical(lipgloss.Left,
 			TitleStyle.Render("Record Detail"),
 			BorderStyle.Render(detailString),
@@ -86,25 +162,92 @@ func (m *Model) renderMainSection() string {
 	}
 
 	// Otherwise, render the list
-	var resultsView string
+	listStyle := BorderStyle
 	if m.InList {
-		resultsView = FocusedBorderStyle.Render(m.List.View())
-	} else {
-		resultsView = BorderStyle.Render(m.List.View())
+		listStyle = FocusedBorderStyle
 	}
-	return lipgloss.JoinVertical(lipgloss.Left,
-		TitleStyle.Render("Results"),
-		resultsView,
-	)
+	if m.Split {
+		// Keep the list's column its full width so the preview doesn't move
+		listStyle = listStyle.Width(m.List.Width() + 2)
+	}
+	resultsView := listStyle.Render(m.List.View())
+	m.mark(zone{ID: zoneList, X: 2, Y: 2, W: m.List.Width(), H: m.List.Height()})
+	sections := []string{TitleStyle.Render("Results"), resultsView}
+	if m.Split {
+		// Preview the highlighted item beside the list, as tall as the list
+		height := lipgloss.Height(resultsView) - 2
+		previewText := m.previewView(m.PreviewWidth-4, height)
+		m.textZone(zonePreview, lipgloss.Width(resultsView)+3, 2, previewText)
+		preview := BorderStyle.Width(m.PreviewWidth - 2).Height(height).Render(previewText)
+		sections = []string{lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.JoinVertical(lipgloss.Left, sections...),
+			" ",
+			lipgloss.JoinVertical(lipgloss.Left, TitleStyle.Render("Preview"), preview),
+		)}
+	}
+	if folder, ok := m.bookmarksLevel(); ok {
+		k := m.Keys
+		bindings := []key.Binding{withHelp(k.Open, "open folder"), withHelp(k.DeleteBookmark, "delete empty folder"),
+			k.RenameFolder, k.BookmarkHere}
+		if folder != "" {
+			bindings = []key.Binding{k.Open, k.DeleteBookmark, k.MoveBookmark, k.BookmarkHere}
+		}
+		sections = append(sections, m.Help.ShortHelpView(bindings))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
 }
 
 // renderChatSection shows the chat viewport and text area.
 func (m *Model) renderChatSection() string {
 	// Chat panel with a distinct border and title
-	chatContent := lipgloss.JoinVertical(lipgloss.Left,
-		m.Chat.Viewport.View(),
-		m.Chat.TextArea.View(),
-	)
+	if m.Chat.ShowSessions {
+		m.mark(zone{ID: zoneSessions, X: 2, Y: 2, W: m.Chat.Sessions.Width(), H: m.Chat.Sessions.Height()})
+		browser := lipgloss.JoinVertical(lipgloss.Left,
+			m.Chat.Sessions.View(),
+			HelpStyle.Render("Enter: Resume | m: Export Markdown | j: Export JSON | d: Delete | Esc: Back"),
+		)
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			FocusedTitleStyle.Render("Chat Panel"),
+			FocusedBorderStyle.Render(browser),
+		)
+	}
+
+	if m.Chat.ShowTemplates {
+		m.mark(zone{ID: zoneTemplates, X: 2, Y: 2, W: m.Chat.Templates.Width(), H: m.Chat.Templates.Height()})
+		palette := lipgloss.JoinVertical(lipgloss.Left,
+			m.Chat.Templates.View(),
+			HelpStyle.Render("Enter: Send (typed text becomes .Input) | Esc: Back"),
+		)
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			FocusedTitleStyle.Render("Chat Panel"),
+			FocusedBorderStyle.Render(palette),
+		)
+	}
+
+	if m.Chat.ShowSettings {
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			FocusedTitleStyle.Render("Chat Panel"),
+			FocusedBorderStyle.Render(m.settingsView()),
+		)
+	}
+
+	var chatParts []string
+	if setup := m.chatSetupLine(); setup != "" {
+		chatParts = append(chatParts, setup)
+	}
+	if usage := m.sessionUsageLine(); usage != "" {
+		chatParts = append(chatParts, usage)
+	}
+	viewportTop := 2
+	for _, part := range chatParts {
+		viewportTop += lipgloss.Height(part)
+	}
+	m.textZone(zoneChat, 2, viewportTop, m.Chat.Viewport.View())
+	chatParts = append(chatParts, m.Chat.Viewport.View(), m.Chat.TextArea.View())
+	chatContent := lipgloss.JoinVertical(lipgloss.Left, chatParts...)
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
 		FocusedTitleStyle.Render("Chat Panel"),
@@ -0,0 +1,178 @@
+// File: /loam/internal/app/vision.go
+
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+	"github.com/bmquinn/loam-iiif/internal/types"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxImageBytes is the largest canvas image we will attach to a message.
+const maxImageBytes = 5 << 20
+
+// ModelCapabilities describes what kinds of input a chat model accepts and
+// the inference parameters it allows. Zero limits are not checked.
+type ModelCapabilities struct {
+	ImageInput bool
+	// MaxImageEdge is the longest image side, in pixels, worth sending.
+	MaxImageEdge int
+
+	MaxOutputTokens int
+	MaxTopK         int
+}
+
+// modelCapabilities maps model id prefixes to their capabilities.
+var modelCapabilities = []struct {
+	prefix string
+	caps   ModelCapabilities
+}{
+	{"amazon.nova-micro", ModelCapabilities{MaxOutputTokens: 10000, MaxTopK: 128}},
+	{"amazon.nova-lite", ModelCapabilities{ImageInput: true, MaxImageEdge: 1024, MaxOutputTokens: 10000, MaxTopK: 128}},
+	{"amazon.nova-pro", ModelCapabilities{ImageInput: true, MaxImageEdge: 1568, MaxOutputTokens: 10000, MaxTopK: 128}},
+	// The mock provider accepts images so the attach flow can be demoed offline
+	{mockModelID, ModelCapabilities{ImageInput: true, MaxImageEdge: 1024}},
+}
+
+// CapabilitiesFor returns the capabilities of a model. Unknown models are
+// assumed to be text-only.
+func CapabilitiesFor(modelID string) ModelCapabilities {
+	modelID = baseModelID(modelID)
+	for _, mc := range modelCapabilities {
+		if strings.HasPrefix(modelID, mc.prefix) {
+			return mc.caps
+		}
+	}
+	return ModelCapabilities{}
+}
+
+// baseModelID strips what inference profiles add to a model id: the ARN
+// prefix, and the region of cross-region profiles, e.g. "us.".
+func baseModelID(modelID string) string {
+	if i := strings.LastIndex(modelID, "/"); i >= 0 {
+		modelID = modelID[i+1:]
+	}
+	if i := strings.Index(modelID, "."); i >= 0 && i <= 4 {
+		modelID = modelID[i+1:]
+	}
+	return modelID
+}
+
+// ImageContent is an image block in a chat message.
+type ImageContent struct {
+	Format string      `json:"format"`
+	Source ImageSource `json:"source"`
+}
+
+// ImageSource holds base64-encoded image bytes.
+type ImageSource struct {
+	Bytes string `json:"bytes"`
+}
+
+// CanvasImageMsg carries a fetched canvas image ready to attach to a message.
+type CanvasImageMsg struct {
+	Label string
+	Image ImageContent
+}
+
+// FetchCanvasImage fetches a canvas image no larger than maxEdge pixels on
+// either side, using the Image API service when the canvas has one and
+// downscaling the image here when it doesn't.
+func FetchCanvasImage(canvas iiif.Canvas, maxEdge int) tea.Cmd {
+	return func() tea.Msg {
+		imageURL := canvas.ImageURL
+		if canvas.ImageService != "" {
+			imageURL = iiif.ImageAPIURL(canvas.ImageService, iiif.ImageRequest{
+				Size: fmt.Sprintf("!%d,%d", maxEdge, maxEdge),
+			})
+		}
+		if imageURL == "" {
+			return types.ErrMsg{Error: fmt.Errorf("canvas has no image")}
+		}
+
+		data, mediaType, err := iiif.FetchImageSync(imageURL, maxImageBytes)
+		if err != nil {
+			return types.ErrMsg{Error: err}
+		}
+		if canvas.ImageService == "" && maxEdge > 0 {
+			if data, mediaType, err = fitImage(data, mediaType, maxEdge); err != nil {
+				return types.ErrMsg{Error: err}
+			}
+		}
+		format, err := imageFormat(mediaType)
+		if err != nil {
+			return types.ErrMsg{Error: err}
+		}
+
+		label := canvas.Label
+		if label == "" {
+			label = canvas.ID
+		}
+		return CanvasImageMsg{
+			Label: label,
+			Image: ImageContent{
+				Format: format,
+				Source: ImageSource{Bytes: base64.StdEncoding.EncodeToString(data)},
+			},
+		}
+	}
+}
+
+// fitImage downscales an image larger than maxEdge pixels on either side,
+// re-encoding it as PNG if it was one and as JPEG otherwise. Smaller images
+// are returned as they are.
+func fitImage(data []byte, mediaType string, maxEdge int) ([]byte, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("canvas image (%s) has no Image API service and can't be resized: %w", mediaType, err)
+	}
+	if cfg.Width <= maxEdge && cfg.Height <= maxEdge {
+		return data, mediaType, nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("canvas image can't be resized: %w", err)
+	}
+	img = downscale(img, maxEdge)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		mediaType = "image/png"
+		err = png.Encode(&buf, img)
+	} else {
+		mediaType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("canvas image can't be resized: %w", err)
+	}
+	return buf.Bytes(), mediaType, nil
+}
+
+// imageFormat converts a media type to the format names Bedrock expects.
+func imageFormat(mediaType string) (string, error) {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return "", fmt.Errorf("unrecognized image type %q", mediaType)
+	}
+	switch mt {
+	case "image/jpeg", "image/jpg":
+		return "jpeg", nil
+	case "image/png":
+		return "png", nil
+	case "image/gif":
+		return "gif", nil
+	case "image/webp":
+		return "webp", nil
+	}
+	return "", fmt.Errorf("unsupported image type %q", mt)
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestFitImage(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		wantW, wantH  int
+		wantUnchanged bool
+		wantErr       bool
+	}{
+		{name: "small enough", data: encode(800, 600), wantW: 800, wantH: 600, wantUnchanged: true},
+		{name: "wide", data: encode(4000, 2000), wantW: 1024, wantH: 512},
+		{name: "tall", data: encode(1500, 3000), wantW: 512, wantH: 1024},
+		{name: "undecodable", data: []byte("RIFF....WEBP"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mediaType, err := fitImage(tt.data, "image/png", 1024)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error for an image that can't be resized")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantUnchanged && !bytes.Equal(data, tt.data) {
+				t.Error("image within the limit was re-encoded")
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH || mediaType != "image/png" {
+				t.Errorf("got %dx%d %s, want %dx%d image/png", cfg.Width, cfg.Height, mediaType, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// DefaultFolder holds bookmarks added before any folder was chosen.
+const DefaultFolder = "Unsorted"
+
+// Bookmark is a pinned collection, manifest or canvas.
+type Bookmark struct {
+	Title string `json:"title"`
+	// URL is the collection or manifest; for a canvas, the manifest it is in.
+	URL    string    `json:"url"`
+	Type   string    `json:"type"`             // Collection, Manifest or Canvas
+	Canvas string    `json:"canvas,omitempty"` // The canvas id, for Canvas bookmarks
+	Added  time.Time `json:"added"`
+}
+
+// ID identifies the bookmarked resource: the canvas id for canvases and the
+// URL otherwise.
+func (b Bookmark) ID() string {
+	if b.Canvas != "" {
+		return b.Canvas
+	}
+	return b.URL
+}
+
+// Folder is a named group of bookmarks.
+type Folder struct {
+	Name      string     `json:"name"`
+	Bookmarks []Bookmark `json:"bookmarks"`
+}
+
+// Bookmarks is the bookmarks file: folders of bookmarks, in the order they
+// are shown.
+type Bookmarks struct {
+	Path    string   `json:"-"`
+	Folders []Folder `json:"folders"`
+}
+
+// DefaultPath returns the location of the bookmarks file in the config directory.
+func DefaultPath() (string, error) {
+	return config.Path("bookmarks.json")
+}
+
+// Load reads the bookmarks at path. A missing file has no bookmarks.
+func Load(path string) (*Bookmarks, error) {
+	b := &Bookmarks{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Save writes the bookmarks to their path as indented JSON.
+func (b *Bookmarks) Save() error {
+	if err := os.MkdirAll(filepath.Dir(b.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create bookmarks directory: %w", err)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %w", err)
+	}
+	return os.WriteFile(b.Path, append(data, '\n'), 0o644)
+}
+
+// Folder returns the folder with the given name, matched case-insensitively,
+// or nil.
+func (b *Bookmarks) Folder(name string) *Folder {
+	for i := range b.Folders {
+		if strings.EqualFold(b.Folders[i].Name, name) {
+			return &b.Folders[i]
+		}
+	}
+	return nil
+}
+
+// folder returns the named folder, creating it if needed.
+func (b *Bookmarks) folder(name string) *Folder {
+	if f := b.Folder(name); f != nil {
+		return f
+	}
+	b.Folders = append(b.Folders, Folder{Name: name})
+	return &b.Folders[len(b.Folders)-1]
+}
+
+// Find returns the folder containing the resource with the given id, or "".
+func (b *Bookmarks) Find(id string) string {
+	for _, f := range b.Folders {
+		for _, bm := range f.Bookmarks {
+			if bm.ID() == id {
+				return f.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Add appends a bookmark to a folder, creating the folder if needed. It
+// reports false if the folder already has the resource.
+func (b *Bookmarks) Add(folder string, bm Bookmark) bool {
+	f := b.folder(folder)
+	for _, existing := range f.Bookmarks {
+		if existing.ID() == bm.ID() {
+			return false
+		}
+	}
+	f.Bookmarks = append(f.Bookmarks, bm)
+	return true
+}
+
+// Remove deletes the bookmark with the given id from a folder.
+func (b *Bookmarks) Remove(folder, id string) (Bookmark, bool) {
+	f := b.Folder(folder)
+	if f == nil {
+		return Bookmark{}, false
+	}
+	for i, bm := range f.Bookmarks {
+		if bm.ID() == id {
+			f.Bookmarks = append(f.Bookmarks[:i], f.Bookmarks[i+1:]...)
+			return bm, true
+		}
+	}
+	return Bookmark{}, false
+}
+
+// Move moves the bookmark with the given id to another folder.
+func (b *Bookmarks) Move(from, id, to string) error {
+	bm, ok := b.Remove(from, id)
+	if !ok {
+		return fmt.Errorf("no bookmark %s in %q", id, from)
+	}
+	b.Add(to, bm)
+	return nil
+}
+
+// Rename renames a folder, merging it into another folder of the new name.
+func (b *Bookmarks) Rename(from, to string) error {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return fmt.Errorf("folder name is empty")
+	}
+	f := b.Folder(from)
+	if f == nil {
+		return fmt.Errorf("no folder %q", from)
+	}
+	if other := b.Folder(to); other == nil || other == f {
+		f.Name = to
+		return nil
+	}
+	moved := f.Bookmarks
+	b.DeleteFolder(from)
+	for _, bm := range moved {
+		b.Add(to, bm)
+	}
+	return nil
+}
+
+// DeleteFolder deletes a folder and its bookmarks.
+func (b *Bookmarks) DeleteFolder(name string) {
+	for i := range b.Folders {
+		if strings.EqualFold(b.Folders[i].Name, name) {
+			b.Folders = append(b.Folders[:i], b.Folders[i+1:]...)
+			return
+		}
+	}
+}
+
+// Merge adds the bookmarks of other that are not already in the same folder,
+// returning how many were added.
+func (b *Bookmarks) Merge(other *Bookmarks) int {
+	added := 0
+	for _, f := range other.Folders {
+		b.folder(f.Name)
+		for _, bm := range f.Bookmarks {
+			if b.Add(f.Name, bm) {
+				added++
+			}
+		}
+	}
+	return added
+}
+
+// Collection renders a folder, or every folder if name is empty, as a IIIF
+// Presentation 3 collection with the given id. Canvas bookmarks are listed
+// as their manifests, since collections can't contain canvases.
+func (b *Bookmarks) Collection(id, name string) ([]byte, error) {
+	label := "Bookmarks"
+	folders := b.Folders
+	if name != "" {
+		f := b.Folder(name)
+		if f == nil {
+			return nil, fmt.Errorf("no folder %q", name)
+		}
+		label += ": " + f.Name
+		folders = []Folder{*f}
+	}
+
+	type item struct {
+		ID    string              `json:"id"`
+		Type  string              `json:"type"`
+		Label map[string][]string `json:"label"`
+	}
+	items := []item{}
+	seen := make(map[string]bool)
+	for _, f := range folders {
+		for _, bm := range f.Bookmarks {
+			if seen[bm.URL] {
+				continue
+			}
+			seen[bm.URL] = true
+			t := bm.Type
+			if t == "Canvas" {
+				t = "Manifest"
+			}
+			items = append(items, item{ID: bm.URL, Type: t, Label: map[string][]string{"none": {bm.Title}}})
+		}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"@context": "http://iiif.io/api/presentation/3/context.json",
+		"id":       id,
+		"type":     "Collection",
+		"label":    map[string][]string{"none": {label}},
+		"items":    items,
+	}, "", "  ")
+}
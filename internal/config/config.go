@@ -0,0 +1,244 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AppName is the directory name used under the user's config directory.
+const AppName = "loam-iiif"
+
+// ContextConfig controls how IIIF resources are rendered into chat context.
+type ContextConfig struct {
+	// TokenBudget is the approximate maximum number of tokens of context sent
+	// with each chat message.
+	TokenBudget int `json:"token_budget,omitempty"`
+
+	// Fields lists the resource sections to include, e.g. "summary" or "metadata".
+	Fields []string `json:"fields,omitempty"`
+}
+
+// ChatConfig controls the chat conversation.
+type ChatConfig struct {
+	// MaxToolSteps limits how many rounds of tool calls the model may make
+	// while answering a single prompt.
+	MaxToolSteps int `json:"max_tool_steps,omitempty"`
+
+	// SystemPrompt replaces the built-in system prompt.
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// Provider is "bedrock" or "mock", an offline provider that answers
+	// from MockScript without any network access.
+	Provider   string `json:"provider,omitempty"`
+	MockScript string `json:"mock_script,omitempty"`
+
+	// Model is the Bedrock model id or inference profile id used for chat,
+	// e.g. "us.amazon.nova-pro-v1:0".
+	Model string `json:"model,omitempty"`
+
+	Inference InferenceConfig `json:"inference"`
+}
+
+// InferenceConfig sets the sampling parameters sent with each chat request.
+// Unset parameters use the model's defaults.
+type InferenceConfig struct {
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// BedrockConfig selects the AWS account, region and endpoints used for Bedrock.
+// Empty fields fall back to the AWS SDK's usual environment variables and
+// shared config files.
+type BedrockConfig struct {
+	Region  string `json:"region,omitempty"`
+	Profile string `json:"profile,omitempty"`
+
+	// EndpointURL replaces the Bedrock Runtime endpoint, e.g. for a VPC
+	// endpoint or a local stand-in. ControlEndpointURL does the same for the
+	// Bedrock API used to list models.
+	EndpointURL        string `json:"endpoint_url,omitempty"`
+	ControlEndpointURL string `json:"control_endpoint_url,omitempty"`
+}
+
+// EmbeddingsConfig selects the provider used to build and query semantic indexes.
+type EmbeddingsConfig struct {
+	// Provider is "bedrock" (Titan embeddings) or "openai" (any
+	// OpenAI-compatible /embeddings endpoint).
+	Provider string `json:"provider,omitempty"`
+	// Model defaults to the provider's standard text embedding model.
+	Model      string `json:"model,omitempty"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	APIKeyEnv  string `json:"api_key_env,omitempty"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	// TopK is how many manifests are retrieved for chat context and search.
+	TopK int `json:"top_k,omitempty"`
+}
+
+// UsageConfig controls the local log of model requests and token counts.
+type UsageConfig struct {
+	// Disabled stops requests from being recorded in usage.jsonl.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Prices maps model ids to their token prices, for `loam-iiif usage`.
+	Prices map[string]Price `json:"prices,omitempty"`
+}
+
+// Price is what a model charges per thousand input and output tokens.
+type Price struct {
+	InputPer1K  float64 `json:"input_per_1k"`
+	OutputPer1K float64 `json:"output_per_1k"`
+}
+
+// Cost returns the price of the given numbers of tokens.
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)/1000*p.InputPer1K + float64(outputTokens)/1000*p.OutputPer1K
+}
+
+// UIConfig controls the layout of the TUI.
+type UIConfig struct {
+	// SplitWidth is the terminal width from which the results list and a
+	// preview of the highlighted item are shown side by side; 0 never splits.
+	SplitWidth int `json:"split_width"`
+
+	// SplitRatio is the fraction of the width given to the list, 0.2-0.8.
+	SplitRatio float64 `json:"split_ratio,omitempty"`
+
+	// RestoreTabs saves the open tabs on exit and opens them again on the
+	// next run.
+	RestoreTabs bool `json:"restore_tabs,omitempty"`
+
+	// Theme is "auto", "dark", "light", "high-contrast", or the name of a
+	// theme file in the themes directory of the config dir.
+	Theme string `json:"theme,omitempty"`
+
+	// Mouse captures the mouse to scroll and click. It can also be toggled
+	// while running, to select text in the terminal.
+	Mouse bool `json:"mouse"`
+}
+
+// Config is the user configuration stored in config.json.
+type Config struct {
+	Context    ContextConfig    `json:"context"`
+	Chat       ChatConfig       `json:"chat"`
+	Bedrock    BedrockConfig    `json:"bedrock"`
+	Embeddings EmbeddingsConfig `json:"embeddings"`
+	Usage      UsageConfig      `json:"usage"`
+	UI         UIConfig         `json:"ui"`
+
+	// Keys rebinds actions in the TUI by name, e.g. "toggle_chat":
+	// ["ctrl+t"]. An empty list unbinds the action.
+	Keys map[string][]string `json:"keys,omitempty"`
+}
+
+// Default returns the configuration used when no config file exists.
+func Default() Config {
+	return Config{
+		Context: ContextConfig{
+			TokenBudget: 4000,
+			Fields:      []string{"label", "summary", "metadata", "rights", "dates", "related", "canvases", "items"},
+		},
+		Chat: ChatConfig{
+			MaxToolSteps: 5,
+			Provider:     "bedrock",
+			Inference: InferenceConfig{
+				MaxTokens: 1000,
+			},
+		},
+		Embeddings: EmbeddingsConfig{
+			Provider:   "bedrock",
+			Endpoint:   "https://api.openai.com/v1",
+			APIKeyEnv:  "OPENAI_API_KEY",
+			Dimensions: 512,
+			TopK:       8,
+		},
+		UI: UIConfig{
+			SplitWidth: 140,
+			SplitRatio: 0.5,
+			Theme:      "auto",
+			Mouse:      true,
+		},
+	}
+}
+
+// Dir returns the loam-iiif config directory, e.g. ~/.config/loam-iiif.
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(base, AppName), nil
+}
+
+// Path returns the location of a file inside the config directory.
+func Path(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Load reads config.json, filling in defaults for anything it leaves unset,
+// and then applies overrides from the environment. A missing file is not an
+// error.
+func Load() (Config, error) {
+	cfg, err := loadFile()
+	applyEnv(&cfg)
+	return cfg, err
+}
+
+func loadFile() (Config, error) {
+	cfg := Default()
+
+	path, err := Path("config.json")
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Default(), fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings from LOAM_* environment variables.
+func applyEnv(cfg *Config) {
+	if v := os.Getenv("LOAM_CHAT_PROVIDER"); v != "" {
+		cfg.Chat.Provider = v
+	}
+	if v := os.Getenv("LOAM_MOCK_SCRIPT"); v != "" {
+		cfg.Chat.MockScript = v
+	}
+	if v := os.Getenv("LOAM_CHAT_MODEL"); v != "" {
+		cfg.Chat.Model = v
+	}
+	if v := os.Getenv("LOAM_BEDROCK_REGION"); v != "" {
+		cfg.Bedrock.Region = v
+	}
+	if v := os.Getenv("LOAM_BEDROCK_PROFILE"); v != "" {
+		cfg.Bedrock.Profile = v
+	}
+	if v := os.Getenv("LOAM_BEDROCK_ENDPOINT_URL"); v != "" {
+		cfg.Bedrock.EndpointURL = v
+	}
+	if v := os.Getenv("LOAM_BEDROCK_CONTROL_ENDPOINT_URL"); v != "" {
+		cfg.Bedrock.ControlEndpointURL = v
+	}
+	if v := os.Getenv("LOAM_THEME"); v != "" {
+		cfg.UI.Theme = v
+	}
+}
@@ -0,0 +1,90 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// MaxEntries is the number of URLs kept; the least recently fetched are
+// dropped first.
+const MaxEntries = 500
+
+// Entry is a URL fetched in the TUI.
+type Entry struct {
+	URL   string    `json:"url"`
+	Title string    `json:"title"`
+	Time  time.Time `json:"time"`
+}
+
+// History is the list of fetched URLs, most recent first, stored as JSON.
+type History struct {
+	Path    string
+	Entries []Entry
+}
+
+// DefaultPath returns the location of the history file in the config directory.
+func DefaultPath() (string, error) {
+	return config.Path("history.json")
+}
+
+// Load reads the history at path. A missing file is an empty history.
+func Load(path string) (*History, error) {
+	h := &History{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.Entries); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Add records a fetch of url, moving it to the front if it was fetched before.
+// An empty title keeps the one recorded earlier.
+func (h *History) Add(url, title string, t time.Time) {
+	for i, e := range h.Entries {
+		if e.URL == url {
+			if title == "" {
+				title = e.Title
+			}
+			h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+			break
+		}
+	}
+	h.Entries = append([]Entry{{URL: url, Title: title, Time: t}}, h.Entries...)
+	if len(h.Entries) > MaxEntries {
+		h.Entries = h.Entries[:MaxEntries]
+	}
+}
+
+// Remove deletes url from the history.
+func (h *History) Remove(url string) {
+	for i, e := range h.Entries {
+		if e.URL == url {
+			h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Save writes the history to its path.
+func (h *History) Save() error {
+	if err := os.MkdirAll(filepath.Dir(h.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(h.Entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	return os.WriteFile(h.Path, data, 0o644)
+}
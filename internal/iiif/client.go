@@ -13,24 +13,40 @@ import (
 
 func FetchData(urlStr string) tea.Cmd {
 	return func() tea.Msg {
-		resp, err := http.Get(urlStr)
+		body, err := fetch(urlStr)
 		if err != nil {
 			return types.ErrMsg{Error: err}
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return types.ErrMsg{Error: fmt.Errorf("failed to fetch data: %s", resp.Status)}
-		}
+		return types.FetchDataMsg(body)
+	}
+}
 
-		body, err := io.ReadAll(resp.Body)
+// FetchDetail fetches a single manifest for the detail pane without
+// replacing the current results list.
+func FetchDetail(urlStr string) tea.Cmd {
+	return func() tea.Msg {
+		body, err := fetch(urlStr)
 		if err != nil {
 			return types.ErrMsg{Error: err}
 		}
-		return types.FetchDataMsg(body)
+		return types.FetchDetailMsg{URL: urlStr, Body: body}
 	}
 }
 
+func fetch(urlStr string) ([]byte, error) {
+	resp, err := http.Get(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch data: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
 func FetchDataSync(urlStr string) ([]byte, error) {
 	resp, err := http.Get(urlStr)
 	if err != nil {
@@ -67,3 +83,31 @@ func OpenURL(urlStr string) error {
 
 	return exec.Command(cmd, args...).Start()
 }
+
+// FetchImageSync downloads an image, returning its bytes and media type.
+// Responses larger than maxBytes are rejected.
+func FetchImageSync(urlStr string, maxBytes int64) ([]byte, string, error) {
+	resp, err := http.Get(urlStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("HTTP GET request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch image: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, "", fmt.Errorf("image is larger than %d bytes", maxBytes)
+	}
+
+	mediaType := resp.Header.Get("Content-Type")
+	if mediaType == "" {
+		mediaType = http.DetectContentType(body)
+	}
+	return body, mediaType, nil
+}
@@ -0,0 +1,40 @@
+package iiif
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultImageSize asks for an image no larger than 1024px on either side.
+// The "!w,h" form is valid in both Image API 2 and 3.
+const DefaultImageSize = "!1024,1024"
+
+// ImageRequest describes the parameters of an Image API request.
+type ImageRequest struct {
+	Region   string // "full", "square", "x,y,w,h" or "pct:x,y,w,h"
+	Size     string // e.g. "!1024,1024", "max", "600,"
+	Rotation int
+	Quality  string // "default", "color", "gray" or "bitonal"
+	Format   string // "jpg", "png", ...
+}
+
+// ImageAPIURL builds an Image API URL for the given service base, filling in
+// defaults for any empty request fields.
+func ImageAPIURL(service string, req ImageRequest) string {
+	if req.Region == "" {
+		req.Region = "full"
+	}
+	if req.Size == "" {
+		req.Size = DefaultImageSize
+	}
+	if req.Quality == "" {
+		req.Quality = "default"
+	}
+	if req.Format == "" {
+		req.Format = "jpg"
+	}
+	return fmt.Sprintf("%s/%s/%s/%d/%s.%s",
+		strings.TrimSuffix(service, "/"),
+		req.Region, req.Size, req.Rotation, req.Quality, req.Format,
+	)
+}
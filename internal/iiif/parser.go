@@ -14,11 +14,16 @@ func ParseData(data []byte) []ui.Item {
 			{URL: "Error", Title: "JSON parse error", ItemType: "Error"},
 		}
 	}
-	return parseIIIF(raw)
+	items := parseIIIF(raw, "")
+	for i := range items {
+		items[i].Order = i
+	}
+	return items
 }
 
 // parseIIIF handles both v3 ("type": "Collection"/"Manifest") and v2 ("@type": "sc:Collection"/"sc:Manifest").
-func parseIIIF(val interface{}) []ui.Item {
+// Items are returned with the label of the collection they are listed in.
+func parseIIIF(val interface{}, parent string) []ui.Item {
 	var out []ui.Item
 
 	m, ok := val.(map[string]interface{})
@@ -33,13 +38,13 @@ func parseIIIF(val interface{}) []ui.Item {
 	case strings.Contains(v2Type, "Collection") || v3Type == "Collection":
 		label := fetchLabel(m)
 		id := fetchID(m)
-		out = append(out, ui.Item{URL: id, Title: label, ItemType: "Collection"})
+		out = append(out, ui.Item{URL: id, Title: label, ItemType: "Collection", Parent: parent, NavDate: navDate(m)})
 
 		// Recurse for v3 "items" array
 		if v3Type == "Collection" {
 			if arr, ok := m["items"].([]interface{}); ok {
 				for _, child := range arr {
-					out = append(out, parseIIIF(child)...)
+					out = append(out, parseIIIF(child, label)...)
 				}
 			}
 		}
@@ -47,24 +52,45 @@ func parseIIIF(val interface{}) []ui.Item {
 		// Recurse for v2 "manifests" / "collections" arrays
 		if manifests, ok := m["manifests"].([]interface{}); ok {
 			for _, child := range manifests {
-				out = append(out, parseIIIF(child)...)
+				out = append(out, parseIIIF(child, label)...)
 			}
 		}
 		if collections, ok := m["collections"].([]interface{}); ok {
 			for _, child := range collections {
-				out = append(out, parseIIIF(child)...)
+				out = append(out, parseIIIF(child, label)...)
 			}
 		}
 
 	case strings.Contains(v2Type, "Manifest") || v3Type == "Manifest":
 		label := fetchLabel(m)
 		id := fetchID(m)
-		out = append(out, ui.Item{URL: id, Title: label, ItemType: "Manifest"})
+		out = append(out, ui.Item{URL: id, Title: label, ItemType: "Manifest", Parent: parent, NavDate: navDate(m),
+			Canvases: countCanvases(m)})
 	}
 
 	return out
 }
 
+func navDate(m map[string]interface{}) string {
+	date, _ := m["navDate"].(string)
+	return date
+}
+
+// countCanvases counts the canvases of a manifest, which a collection may
+// or may not embed: v3 "items", or v2 "sequences" of "canvases".
+func countCanvases(m map[string]interface{}) int {
+	if items, ok := m["items"].([]interface{}); ok {
+		return len(items)
+	}
+	if sequences, ok := m["sequences"].([]interface{}); ok && len(sequences) > 0 {
+		if seq, ok := sequences[0].(map[string]interface{}); ok {
+			canvases, _ := seq["canvases"].([]interface{})
+			return len(canvases)
+		}
+	}
+	return 0
+}
+
 func fetchID(m map[string]interface{}) string {
 	if id, ok := m["id"].(string); ok {
 		return id
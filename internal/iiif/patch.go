@@ -0,0 +1,187 @@
+package iiif
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// presentation3Context identifies a Presentation API 3 document.
+const presentation3Context = "http://iiif.io/api/presentation/3/context.json"
+
+// Fields of a manifest that a FieldChange can patch.
+const (
+	PatchLabel    = "label"
+	PatchSummary  = "summary"
+	PatchMetadata = "metadata"
+)
+
+// FieldChange is a proposed change to one language of a manifest's label,
+// summary or a metadata entry.
+type FieldChange struct {
+	Field    string // PatchLabel, PatchSummary or PatchMetadata
+	Language string // Language map key, e.g. "en" or "none"
+	Label    string // The metadata entry's label, for PatchMetadata
+	Old      string // The current value, empty when the change adds one
+	New      string
+}
+
+// String describes the field the change applies to, e.g. `metadata "Date" [en]`.
+func (c FieldChange) String() string {
+	if c.Field == PatchMetadata {
+		return fmt.Sprintf("%s %q [%s]", c.Field, c.Label, c.Language)
+	}
+	return fmt.Sprintf("%s [%s]", c.Field, c.Language)
+}
+
+// IsPresentation3 reports whether data is a Presentation API 3 resource.
+func IsPresentation3(data []byte) bool {
+	var doc struct {
+		Context interface{} `json:"@context"`
+	}
+	if json.Unmarshal(data, &doc) != nil {
+		return false
+	}
+	switch ctx := doc.Context.(type) {
+	case string:
+		return ctx == presentation3Context
+	case []interface{}:
+		for _, c := range ctx {
+			if c == presentation3Context {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiffChanges fills in the current value of each change from the manifest in
+// data and drops changes that would leave the manifest as it is.
+func DiffChanges(data []byte, changes []FieldChange) ([]FieldChange, error) {
+	doc, err := decodeManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff []FieldChange
+	for _, c := range changes {
+		c.New = strings.TrimSpace(c.New)
+		if c.Language == "" {
+			c.Language = "none"
+		}
+		c.Old = ""
+		switch c.Field {
+		case PatchLabel, PatchSummary:
+			c.Old = languageMapValue(doc[c.Field], c.Language)
+		case PatchMetadata:
+			if entry := findMetadata(doc, c.Label); entry != nil {
+				c.Old = languageMapValue(entry["value"], c.Language)
+			}
+		default:
+			return nil, fmt.Errorf("cannot patch %q", c.Field)
+		}
+		if c.New != "" && c.New != c.Old {
+			diff = append(diff, c)
+		}
+	}
+	return diff, nil
+}
+
+// PatchManifest applies changes to a Presentation 3 manifest and returns the
+// patched manifest as indented JSON. Each change replaces the value for its
+// language, leaving other languages alone; metadata changes for a label the
+// manifest does not have add a new entry.
+func PatchManifest(data []byte, changes []FieldChange) ([]byte, error) {
+	doc, err := decodeManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		switch c.Field {
+		case PatchLabel, PatchSummary:
+			doc[c.Field] = setLanguage(doc[c.Field], c.Language, c.New)
+		case PatchMetadata:
+			if entry := findMetadata(doc, c.Label); entry != nil {
+				entry["value"] = setLanguage(entry["value"], c.Language, c.New)
+				continue
+			}
+			metadata, _ := doc["metadata"].([]interface{})
+			doc["metadata"] = append(metadata, map[string]interface{}{
+				"label": map[string]interface{}{c.Language: []interface{}{c.Label}},
+				"value": map[string]interface{}{c.Language: []interface{}{c.New}},
+			})
+		default:
+			return nil, fmt.Errorf("cannot patch %q", c.Field)
+		}
+	}
+
+	// Summaries and metadata values are often HTML, which must come out as
+	// it went in rather than escaped
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// decodeManifest decodes a Presentation 3 manifest for patching.
+func decodeManifest(data []byte) (map[string]interface{}, error) {
+	if !IsPresentation3(data) {
+		return nil, fmt.Errorf("only Presentation 3 manifests can be patched")
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if t, _ := doc["type"].(string); t != "Manifest" {
+		return nil, fmt.Errorf("only manifests can be patched, not a %s", t)
+	}
+	return doc, nil
+}
+
+// findMetadata returns the metadata entry whose label, in any language,
+// matches label case-insensitively.
+func findMetadata(doc map[string]interface{}, label string) map[string]interface{} {
+	metadata, _ := doc["metadata"].([]interface{})
+	for _, e := range metadata {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		labels, _ := entry["label"].(map[string]interface{})
+		for _, values := range labels {
+			vs, _ := values.([]interface{})
+			for _, v := range vs {
+				if s, ok := v.(string); ok && strings.EqualFold(strings.TrimSpace(s), strings.TrimSpace(label)) {
+					return entry
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// languageMapValue returns the values of one language in a language map.
+func languageMapValue(val interface{}, language string) string {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	return languageValue(m[language])
+}
+
+// setLanguage returns the language map val with the values for language
+// replaced by value.
+func setLanguage(val interface{}, language, value string) map[string]interface{} {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	m[language] = []interface{}{value}
+	return m
+}
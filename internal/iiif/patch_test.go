@@ -0,0 +1,30 @@
+package iiif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchManifestKeepsHTML(t *testing.T) {
+	manifest := `{
+  "@context": "http://iiif.io/api/presentation/3/context.json",
+  "id": "https://example.org/manifest",
+  "type": "Manifest",
+  "label": {"en": ["Map"]},
+  "metadata": [{"label": {"en": ["Source"]}, "value": {"en": ["<a href=\"https://example.org/?a=1&b=2\">Archive</a>"]}}]
+}`
+	out, err := PatchManifest([]byte(manifest), []FieldChange{
+		{Field: PatchSummary, Language: "en", New: "A <b>hand-drawn</b> map"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<a href=\"https://example.org/?a=1&b=2\">Archive</a>`, `A <b>hand-drawn</b> map`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("patched manifest lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.HasSuffix(string(out), "\n") {
+		t.Error("patched manifest ends with a newline")
+	}
+}
@@ -0,0 +1,311 @@
+package iiif
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/ui"
+)
+
+// MetadataEntry is a single label/value pair from a resource's metadata block.
+type MetadataEntry struct {
+	Label string
+	Value string
+}
+
+// Canvas holds the descriptive fields of a single canvas in a manifest.
+type Canvas struct {
+	ID           string
+	Label        string
+	Width        int
+	Height       int
+	ImageService string // Image API service base URL, if any
+	ImageURL     string // Direct URL of the painting annotation body
+}
+
+// Resource is a richer view of a Collection or Manifest than ui.Item. It keeps
+// the descriptive properties (summary, metadata, rights, dates, canvases) that
+// the list view does not need but the chat context does.
+type Resource struct {
+	ID                string
+	Type              string
+	Label             string
+	Summary           string
+	Metadata          []MetadataEntry
+	Rights            string
+	RequiredStatement *MetadataEntry
+	NavDate           string
+	Thumbnail         string // URL of the thumbnail image, if any
+	Canvases          []Canvas
+	Items             []ui.Item // Direct members of a collection
+}
+
+// ParseResource decodes a v2 or v3 Collection or Manifest into a Resource.
+func ParseResource(data []byte) (*Resource, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse resource: %w", err)
+	}
+	return resourceFromMap(raw), nil
+}
+
+// MetadataMap flattens the metadata into label/value pairs, joining the
+// values of repeated labels. It returns nil if there is no metadata.
+func (r *Resource) MetadataMap() map[string]string {
+	if len(r.Metadata) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(r.Metadata))
+	for _, e := range r.Metadata {
+		if prev, ok := m[e.Label]; ok {
+			m[e.Label] = prev + "; " + e.Value
+		} else {
+			m[e.Label] = e.Value
+		}
+	}
+	return m
+}
+
+func resourceFromMap(m map[string]interface{}) *Resource {
+	res := &Resource{
+		ID:    fetchID(m),
+		Type:  normalizeType(m),
+		Label: languageValue(m["label"]),
+	}
+
+	// v3 summary, v2 description
+	res.Summary = languageValue(m["summary"])
+	if res.Summary == "" {
+		res.Summary = languageValue(m["description"])
+	}
+
+	if arr, ok := m["metadata"].([]interface{}); ok {
+		for _, entry := range arr {
+			if e, ok := metadataEntry(entry); ok {
+				res.Metadata = append(res.Metadata, e)
+			}
+		}
+	}
+
+	// v3 rights, v2 license
+	if s, ok := m["rights"].(string); ok {
+		res.Rights = s
+	} else {
+		res.Rights = languageValue(m["license"])
+	}
+
+	// v3 requiredStatement, v2 attribution
+	if e, ok := metadataEntry(m["requiredStatement"]); ok {
+		res.RequiredStatement = &e
+	} else if s := languageValue(m["attribution"]); s != "" {
+		res.RequiredStatement = &MetadataEntry{Label: "Attribution", Value: s}
+	}
+
+	res.NavDate, _ = m["navDate"].(string)
+	res.Thumbnail = thumbnailURL(m["thumbnail"])
+
+	switch res.Type {
+	case "Collection":
+		for _, key := range []string{"items", "collections", "manifests"} {
+			if arr, ok := m[key].([]interface{}); ok {
+				for _, child := range arr {
+					if cm, ok := child.(map[string]interface{}); ok {
+						res.Items = append(res.Items, ui.Item{
+							URL:      fetchID(cm),
+							Title:    fetchLabel(cm),
+							ItemType: normalizeType(cm),
+						})
+					}
+				}
+			}
+		}
+	case "Manifest":
+		res.Canvases = parseCanvases(m)
+	}
+
+	return res
+}
+
+// thumbnailURL returns the URL of the first image in a v3 thumbnail array or
+// a v2 thumbnail string or object.
+func thumbnailURL(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		return fetchID(v)
+	case []interface{}:
+		if len(v) > 0 {
+			return thumbnailURL(v[0])
+		}
+	}
+	return ""
+}
+
+// normalizeType returns "Collection" or "Manifest" for v2 and v3 resources,
+// falling back to the raw type with any "sc:" prefix removed.
+func normalizeType(m map[string]interface{}) string {
+	v3Type, _ := m["type"].(string)
+	v2Type, _ := m["@type"].(string)
+	switch {
+	case strings.Contains(v2Type, "Collection") || v3Type == "Collection":
+		return "Collection"
+	case strings.Contains(v2Type, "Manifest") || v3Type == "Manifest":
+		return "Manifest"
+	}
+	return strings.TrimPrefix(v3Type+v2Type, "sc:")
+}
+
+// parseCanvases walks v3 "items" or the first v2 sequence's "canvases".
+func parseCanvases(m map[string]interface{}) []Canvas {
+	var raw []interface{}
+	if arr, ok := m["items"].([]interface{}); ok {
+		raw = arr
+	} else if seqs, ok := m["sequences"].([]interface{}); ok && len(seqs) > 0 {
+		if seq, ok := seqs[0].(map[string]interface{}); ok {
+			raw, _ = seq["canvases"].([]interface{})
+		}
+	}
+
+	var canvases []Canvas
+	for _, c := range raw {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		canvas := Canvas{
+			ID:     fetchID(cm),
+			Label:  languageValue(cm["label"]),
+			Width:  intValue(cm["width"]),
+			Height: intValue(cm["height"]),
+		}
+		if body := paintingBody(cm); body != nil {
+			canvas.ImageURL = fetchID(body)
+			canvas.ImageService = serviceID(body["service"])
+		}
+		canvases = append(canvases, canvas)
+	}
+	return canvases
+}
+
+// paintingBody returns the first image body painted on a canvas, looking at
+// v3 items[].items[].body and v2 images[].resource.
+func paintingBody(canvas map[string]interface{}) map[string]interface{} {
+	if pages, ok := canvas["items"].([]interface{}); ok {
+		for _, p := range pages {
+			page, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			annos, _ := page["items"].([]interface{})
+			for _, a := range annos {
+				anno, ok := a.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				switch body := anno["body"].(type) {
+				case map[string]interface{}:
+					return body
+				case []interface{}:
+					if len(body) > 0 {
+						if b, ok := body[0].(map[string]interface{}); ok {
+							return b
+						}
+					}
+				}
+			}
+		}
+	}
+	if images, ok := canvas["images"].([]interface{}); ok && len(images) > 0 {
+		if img, ok := images[0].(map[string]interface{}); ok {
+			if res, ok := img["resource"].(map[string]interface{}); ok {
+				return res
+			}
+		}
+	}
+	return nil
+}
+
+// serviceID returns the id of the first service in a v2 object or v3 array.
+func serviceID(val interface{}) string {
+	switch s := val.(type) {
+	case map[string]interface{}:
+		return fetchID(s)
+	case []interface{}:
+		for _, entry := range s {
+			if sm, ok := entry.(map[string]interface{}); ok {
+				if id := fetchID(sm); id != "" {
+					return id
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func metadataEntry(val interface{}) (MetadataEntry, bool) {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return MetadataEntry{}, false
+	}
+	e := MetadataEntry{
+		Label: languageValue(m["label"]),
+		Value: languageValue(m["value"]),
+	}
+	return e, e.Label != "" || e.Value != ""
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// languageValue flattens a v3 language map, a v2 string/array/@value object,
+// or a plain string into a single string with HTML markup removed.
+func languageValue(val interface{}) string {
+	var parts []string
+
+	switch v := val.(type) {
+	case string:
+		parts = append(parts, v)
+	case []interface{}:
+		for _, entry := range v {
+			if s := languageValue(entry); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	case map[string]interface{}:
+		if s, ok := v["@value"].(string); ok {
+			parts = append(parts, s)
+			break
+		}
+		// Prefer English, then no-language, then whatever is present.
+		for _, key := range []string{"en", "none"} {
+			if s := languageValue(v[key]); s != "" {
+				return s
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if s := languageValue(v[key]); s != "" {
+				return s
+			}
+		}
+	}
+
+	out := strings.Join(parts, "; ")
+	out = html.UnescapeString(tagPattern.ReplaceAllString(out, ""))
+	return strings.TrimSpace(out)
+}
+
+func intValue(val interface{}) int {
+	if f, ok := val.(float64); ok {
+		return int(f)
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/iiif"
+)
+
+// BuildOptions controls how a collection is crawled and embedded.
+type BuildOptions struct {
+	Concurrency int // Parallel manifest fetches
+	BatchSize   int // Texts per Embed call
+	// Progress, if non-nil, is called after each batch is embedded.
+	Progress func(done, total int)
+}
+
+// Build crawls a collection and its sub-collections, embeds the label,
+// summary and metadata of every manifest, and returns the resulting index.
+func Build(ctx context.Context, collectionURL string, embedder Embedder, opts BuildOptions) (*Index, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 16
+	}
+
+	root, manifests, err := Crawl(ctx, collectionURL)
+	if err != nil {
+		return nil, err
+	}
+	entries := fetchManifests(ctx, manifests, opts.Concurrency)
+
+	for start := 0; start < len(entries); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		texts := make([]string, 0, end-start)
+		for _, e := range entries[start:end] {
+			texts = append(texts, e.Text)
+		}
+
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vectors {
+			entries[start+i].Vector = v
+		}
+		if opts.Progress != nil {
+			opts.Progress(end, len(entries))
+		}
+	}
+
+	ix := &Index{
+		Collection: root.ID,
+		Provider:   embedder.Provider(),
+		Model:      embedder.Model(),
+		Created:    time.Now().UTC(),
+		Entries:    entries,
+	}
+	if len(entries) > 0 {
+		ix.Dimensions = len(entries[0].Vector)
+	}
+	return ix, nil
+}
+
+// Crawl fetches a collection and returns it with the URLs of every manifest
+// in it and its sub-collections.
+func Crawl(ctx context.Context, collectionURL string) (*iiif.Resource, []string, error) {
+	root, err := fetch(collectionURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root.Type != "Collection" {
+		return nil, nil, fmt.Errorf("%s is a %s, not a Collection", collectionURL, root.Type)
+	}
+
+	manifests, err := crawl(ctx, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, manifests, nil
+}
+
+func fetch(urlStr string) (*iiif.Resource, error) {
+	data, err := iiif.FetchDataSync(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return iiif.ParseResource(data)
+}
+
+// crawl walks nested collections breadth-first and returns the URLs of every
+// manifest found, skipping collections already visited.
+func crawl(ctx context.Context, root *iiif.Resource) ([]string, error) {
+	seen := map[string]bool{root.ID: true}
+	queue := []*iiif.Resource{root}
+	var manifests []string
+
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		coll := queue[0]
+		queue = queue[1:]
+
+		for _, item := range coll.Items {
+			if seen[item.URL] {
+				continue
+			}
+			seen[item.URL] = true
+
+			switch item.ItemType {
+			case "Manifest":
+				manifests = append(manifests, item.URL)
+			case "Collection":
+				child, err := fetch(item.URL)
+				if err != nil {
+					continue
+				}
+				queue = append(queue, child)
+			}
+		}
+	}
+	return manifests, nil
+}
+
+// fetchManifests fetches manifests in parallel, skipping any that fail.
+func fetchManifests(ctx context.Context, urls []string, concurrency int) []Entry {
+	results := make([]*Entry, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			res, err := fetch(u)
+			if err != nil {
+				return
+			}
+			results[i] = &Entry{
+				ID:       u,
+				Label:    res.Label,
+				Type:     res.Type,
+				Text:     DocumentText(res),
+				Metadata: res.MetadataMap(),
+			}
+		}(i, u)
+	}
+	wg.Wait()
+
+	entries := make([]Entry, 0, len(results))
+	for _, e := range results {
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	return entries
+}
+
+// DocumentText is the text embedded for a manifest: its label, summary and
+// metadata values.
+func DocumentText(res *iiif.Resource) string {
+	var sb strings.Builder
+	sb.WriteString(res.Label)
+	if res.Summary != "" {
+		sb.WriteString("\n" + res.Summary)
+	}
+	for _, e := range res.Metadata {
+		fmt.Fprintf(&sb, "\n%s: %s", e.Label, e.Value)
+	}
+	return sb.String()
+}
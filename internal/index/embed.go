@@ -0,0 +1,214 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// Embedding providers.
+const (
+	ProviderBedrock = "bedrock"
+	ProviderOpenAI  = "openai"
+)
+
+// Embedder turns text into vectors.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Provider() string
+	Model() string
+}
+
+// RequestUsage is the input token count and latency of one embedding
+// request. Estimated is set when the provider reported no count and it was
+// estimated from the text.
+type RequestUsage struct {
+	InputTokens int
+	Estimated   bool
+	Latency     time.Duration
+}
+
+// estimateTokens approximates the token count of texts, at about four
+// characters a token.
+func estimateTokens(texts []string) int {
+	n := 0
+	for _, text := range texts {
+		n += (len([]rune(text)) + 3) / 4
+	}
+	return n
+}
+
+// BedrockEmbedder calls an Amazon Titan text embeddings model.
+type BedrockEmbedder struct {
+	Client     *bedrockruntime.Client
+	ModelID    string
+	Dimensions int
+
+	// OnRequest, if set, is called with the usage of each request.
+	OnRequest func(RequestUsage)
+}
+
+type titanRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Normalize  bool   `json:"normalize"`
+}
+
+type titanResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// Provider implements Embedder.
+func (e *BedrockEmbedder) Provider() string { return ProviderBedrock }
+
+// Model implements Embedder.
+func (e *BedrockEmbedder) Model() string { return e.ModelID }
+
+// Embed implements Embedder. Titan embeds one text per request.
+func (e *BedrockEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		body, err := json.Marshal(titanRequest{
+			InputText:  text,
+			Dimensions: e.Dimensions,
+			Normalize:  true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+		}
+
+		start := time.Now()
+		output, err := e.Client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(e.ModelID),
+			ContentType: aws.String("application/json"),
+			Body:        body,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to invoke embedding model: %w", err)
+		}
+
+		var resp titanResponse
+		if err := json.Unmarshal(output.Body, &resp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+		}
+		if e.OnRequest != nil {
+			u := RequestUsage{InputTokens: resp.InputTextTokenCount, Latency: time.Since(start)}
+			if u.InputTokens == 0 {
+				u.InputTokens, u.Estimated = estimateTokens([]string{text}), true
+			}
+			e.OnRequest(u)
+		}
+		vectors = append(vectors, resp.Embedding)
+	}
+	return vectors, nil
+}
+
+// OpenAIEmbedder calls an OpenAI-compatible /embeddings endpoint.
+type OpenAIEmbedder struct {
+	Endpoint   string // Base URL, e.g. https://api.openai.com/v1
+	APIKey     string
+	ModelID    string
+	Dimensions int
+	HTTPClient *http.Client
+
+	// OnRequest, if set, is called with the usage of each request.
+	OnRequest func(RequestUsage)
+}
+
+type openAIRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// Provider implements Embedder.
+func (e *OpenAIEmbedder) Provider() string { return ProviderOpenAI }
+
+// Model implements Embedder.
+func (e *OpenAIEmbedder) Model() string { return e.ModelID }
+
+// Embed implements Embedder, sending all texts in a single request.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:      e.ModelID,
+		Input:      texts,
+		Dimensions: e.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(e.Endpoint, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var out openAIResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+	}
+	if e.OnRequest != nil {
+		u := RequestUsage{Latency: time.Since(start)}
+		if out.Usage != nil && out.Usage.PromptTokens > 0 {
+			u.InputTokens = out.Usage.PromptTokens
+		} else {
+			u.InputTokens, u.Estimated = estimateTokens(texts), true
+		}
+		e.OnRequest(u)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(out.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,47 @@
+package index
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIEmbedderReportsUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want RequestUsage
+	}{
+		{
+			name: "reported",
+			body: `{"data": [{"index": 0, "embedding": [1, 0]}], "usage": {"prompt_tokens": 7}}`,
+			want: RequestUsage{InputTokens: 7},
+		},
+		{
+			name: "estimated",
+			body: `{"data": [{"index": 0, "embedding": [1, 0]}]}`,
+			want: RequestUsage{InputTokens: 4, Estimated: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			var got []RequestUsage
+			e := &OpenAIEmbedder{Endpoint: srv.URL, ModelID: "test", OnRequest: func(u RequestUsage) {
+				u.Latency = 0
+				got = append(got, u)
+			}}
+			if _, err := e.Embed(context.Background(), []string{"a map of Paris"}); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("usage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package index
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// ErrNotFound is returned by Load when no index exists for a collection.
+var ErrNotFound = errors.New("no index for collection")
+
+// Entry is one embedded manifest.
+type Entry struct {
+	ID       string            `json:"id"`
+	Label    string            `json:"label"`
+	Type     string            `json:"type"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector"`
+}
+
+// Index is a file-based vector index over the manifests of one collection.
+type Index struct {
+	Collection string    `json:"collection"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	Created    time.Time `json:"created"`
+	Entries    []Entry   `json:"entries"`
+}
+
+// Hit is a search result with its cosine similarity to the query.
+type Hit struct {
+	Entry
+	Score float64
+}
+
+// PathFor returns where the index for a collection URL is stored.
+func PathFor(collectionURL string) (string, error) {
+	sum := sha1.Sum([]byte(collectionURL))
+	return config.Path(filepath.Join("indexes", hex.EncodeToString(sum[:])+".json"))
+}
+
+// Load reads the index for a collection URL.
+func Load(collectionURL string) (*Index, error) {
+	path, err := PathFor(collectionURL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", path, err)
+	}
+	return &ix, nil
+}
+
+// Save writes the index next to the other loam-iiif config files.
+func (ix *Index) Save() error {
+	path, err := PathFor(ix.Collection)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted save keeps the old index.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Search returns the k entries most similar to vec.
+func (ix *Index) Search(vec []float32, k int) []Hit {
+	hits := make([]Hit, 0, len(ix.Entries))
+	for _, e := range ix.Entries {
+		hits = append(hits, Hit{Entry: e, Score: cosine(vec, e.Vector)})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if k > 0 && len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -2,6 +2,13 @@ package types
 
 type FetchDataMsg []byte
 
+// FetchDetailMsg carries the raw body of a manifest opened in the detail
+// pane, and the URL it was fetched from.
+type FetchDetailMsg struct {
+	URL  string
+	Body []byte
+}
+
 type ErrMsg struct {
 	Error error
 }
@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// SortMode is the order of the items in the results list.
+type SortMode string
+
+const (
+	SortOriginal SortMode = "original" // As listed by the collection
+	SortLabel    SortMode = "label"
+	SortType     SortMode = "type"     // Collections, then manifests
+	SortDate     SortMode = "date"     // By navDate, oldest first
+	SortCanvases SortMode = "canvases" // Most canvases first
+)
+
+// SortModes are the sort modes in the order they are cycled through.
+var SortModes = []SortMode{SortOriginal, SortLabel, SortType, SortDate, SortCanvases}
+
+// GroupMode is how the results list is divided into sections.
+type GroupMode string
+
+const (
+	GroupNone   GroupMode = "none"
+	GroupType   GroupMode = "type"
+	GroupParent GroupMode = "parent" // By the collection an item is listed in
+)
+
+// GroupModes are the group modes in the order they are cycled through.
+var GroupModes = []GroupMode{GroupNone, GroupType, GroupParent}
+
+// Arrangement is how the results list is sorted and grouped. The zero value
+// is the original order, ungrouped.
+type Arrangement struct {
+	Sort  SortMode  `json:"sort,omitempty"`
+	Group GroupMode `json:"group,omitempty"`
+}
+
+// IsDefault reports whether a leaves the list as it was listed.
+func (a Arrangement) IsDefault() bool {
+	return (a.Sort == "" || a.Sort == SortOriginal) && (a.Group == "" || a.Group == GroupNone)
+}
+
+// Next returns the mode after mode in modes, wrapping around.
+func Next[T comparable](modes []T, mode T) T {
+	for i, m := range modes {
+		if m == mode {
+			return modes[(i+1)%len(modes)]
+		}
+	}
+	return modes[min(1, len(modes)-1)]
+}
+
+// Header heads a section of the results list when it is grouped. Headers
+// match no filter and can't be opened.
+type Header struct {
+	Title string
+	Count int
+}
+
+func (h Header) FilterValue() string { return "" }
+
+// Arrange sorts and groups items, dropping any headers from an earlier
+// arrangement. Each group is headed by a Header.
+func Arrange(items []list.Item, a Arrangement) []list.Item {
+	var sorted []Item
+	for _, li := range items {
+		if item, ok := li.(Item); ok {
+			sorted = append(sorted, item)
+		}
+	}
+
+	group := groupKey(a.Group)
+	groupRank := make(map[string]int)
+	byOrder := append([]Item(nil), sorted...)
+	sort.SliceStable(byOrder, func(i, j int) bool { return byOrder[i].Order < byOrder[j].Order })
+	for _, item := range byOrder {
+		key := group(item)
+		if _, ok := groupRank[key]; !ok {
+			groupRank[key] = len(groupRank)
+		}
+	}
+	if a.Group == GroupType {
+		// Collections and manifests come first, whatever is listed first
+		for key := range groupRank {
+			groupRank[key] += typeRank(key) * len(groupRank)
+		}
+	}
+
+	less := sortLess(a.Sort)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		gi, gj := groupRank[group(sorted[i])], groupRank[group(sorted[j])]
+		if gi != gj {
+			return gi < gj
+		}
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].Order < sorted[j].Order
+	})
+
+	grouped := a.Group == GroupType || a.Group == GroupParent
+	out := make([]list.Item, 0, len(sorted))
+	header := -1
+	for i, item := range sorted {
+		if grouped && (i == 0 || group(sorted[i-1]) != group(item)) {
+			header = len(out)
+			out = append(out, Header{Title: groupTitle(a.Group, group(item))})
+		}
+		if header >= 0 {
+			h := out[header].(Header)
+			h.Count++
+			out[header] = h
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func groupKey(mode GroupMode) func(Item) string {
+	switch mode {
+	case GroupType:
+		return func(i Item) string { return i.ItemType }
+	case GroupParent:
+		return func(i Item) string { return i.Parent }
+	}
+	return func(Item) string { return "" }
+}
+
+func groupTitle(mode GroupMode, key string) string {
+	switch {
+	case mode == GroupParent && key == "":
+		return "Not in a collection"
+	case mode == GroupParent:
+		return key
+	case key == "":
+		return "Other"
+	case strings.HasSuffix(key, "s"):
+		return key + "es"
+	}
+	return key + "s"
+}
+
+// typeRank puts collections before manifests before anything else.
+func typeRank(itemType string) int {
+	switch {
+	case strings.EqualFold(itemType, "collection"):
+		return 0
+	case strings.EqualFold(itemType, "manifest"):
+		return 1
+	}
+	return 2
+}
+
+// sortLess orders items by mode. Items it can't tell apart are left in
+// their original order; those missing a date or canvas count go last.
+func sortLess(mode SortMode) func(a, b Item) bool {
+	switch mode {
+	case SortLabel:
+		return func(a, b Item) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	case SortType:
+		return func(a, b Item) bool { return typeRank(a.ItemType) < typeRank(b.ItemType) }
+	case SortDate:
+		return func(a, b Item) bool {
+			if a.NavDate == "" || b.NavDate == "" {
+				return a.NavDate != ""
+			}
+			return a.NavDate < b.NavDate
+		}
+	case SortCanvases:
+		return func(a, b Item) bool { return a.Canvases > b.Canvases }
+	}
+	return func(a, b Item) bool { return false }
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"io"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -14,20 +15,36 @@ type ItemDelegate struct {
 	Width  int
 	Styles struct {
 		SelectedTitle, SelectedDesc, NormalTitle, NormalDesc lipgloss.Style
+		// Match highlights the characters a filter query matched.
+		Match lipgloss.Style
+		// Header and Rule draw the section headers of a grouped list.
+		Header, Rule lipgloss.Style
 	}
 }
 
-func NewItemDelegate(width int) ItemDelegate {
+// NewItemDelegate renders items width cells wide in the colors of theme.
+func NewItemDelegate(width int, theme Theme) ItemDelegate {
 	d := ItemDelegate{Width: width}
 	d.Styles.SelectedTitle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
+		Foreground(theme.Accent).
 		Bold(true)
 	d.Styles.SelectedDesc = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(theme.Muted)
 	d.Styles.NormalTitle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205"))
+		Foreground(theme.Accent)
 	d.Styles.NormalDesc = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(theme.Muted)
+	d.Styles.Match = lipgloss.NewStyle().
+		Underline(true)
+	d.Styles.Header = lipgloss.NewStyle().
+		Foreground(theme.Text).
+		Bold(true)
+	d.Styles.Rule = lipgloss.NewStyle().
+		Foreground(theme.Border)
+	if theme.NoColor {
+		// Without color, only the selected item's title stands out
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.Reverse(true)
+	}
 	return d
 }
 
@@ -44,6 +61,13 @@ func (d ItemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd {
 }
 
 func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	if h, ok := listItem.(Header); ok {
+		title := truncateString(fmt.Sprintf("%s (%d)", h.Title, h.Count), d.Width-5)
+		rule := strings.Repeat("─", max(d.Width-5, 0))
+		fmt.Fprintf(w, "%s\n%s", d.Styles.Header.Render(title), d.Styles.Rule.Render(rule))
+		return
+	}
+
 	i, ok := listItem.(Item)
 	if !ok {
 		return
@@ -55,18 +79,31 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	truncatedTitle := truncateString(i.Title, maxTitleLen)
 	truncatedDesc := truncateString(i.URL, maxDescLen)
 
-	var title, desc string
+	titleStyle, descStyle := d.Styles.NormalTitle, d.Styles.NormalDesc
 	if index == m.Index() {
-		title = d.Styles.SelectedTitle.Render(truncatedTitle)
-		desc = d.Styles.SelectedDesc.Render(truncatedDesc)
-	} else {
-		title = d.Styles.NormalTitle.Render(truncatedTitle)
-		desc = d.Styles.NormalDesc.Render(truncatedDesc)
+		titleStyle, descStyle = d.Styles.SelectedTitle, d.Styles.SelectedDesc
 	}
 
+	title := titleStyle.Render(truncatedTitle)
+	if matches := visibleMatches(m.MatchesForItem(index), utf8.RuneCountInString(truncatedTitle)); len(matches) > 0 {
+		title = lipgloss.StyleRunes(truncatedTitle, matches, titleStyle.Inherit(d.Styles.Match), titleStyle)
+	}
+	desc := descStyle.Render(truncatedDesc)
+
 	fmt.Fprintf(w, "%s\n%s", title, desc)
 }
 
+// visibleMatches drops match positions beyond the displayed title.
+func visibleMatches(matches []int, length int) []int {
+	var visible []int
+	for _, i := range matches {
+		if i < length {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
 func truncateString(s string, length int) string {
 	if utf8.RuneCountInString(s) <= length {
 		return s
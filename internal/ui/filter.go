@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/sahilm/fuzzy"
+)
+
+// Separators used to pack an item's searchable fields into its FilterValue.
+const (
+	fieldSep = "\x1f"
+	pairSep  = "\x1e"
+)
+
+// filterFields are the fields of an item that a filter query can search.
+type filterFields struct {
+	title    string
+	id       string
+	itemType string
+	metadata [][2]string // Label, value
+}
+
+// filterValue packs the title (first, so that match positions in it line up
+// with the title), id, type and metadata of an item.
+func (i Item) filterValue() string {
+	parts := []string{i.Title, i.URL, i.ItemType}
+	labels := make([]string, 0, len(i.Metadata))
+	for label := range i.Metadata {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		parts = append(parts, label+pairSep+i.Metadata[label])
+	}
+	return strings.Join(parts, fieldSep)
+}
+
+func parseFilterValue(s string) filterFields {
+	parts := strings.Split(s, fieldSep)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	f := filterFields{title: parts[0], id: parts[1], itemType: parts[2]}
+	for _, p := range parts[3:] {
+		if label, value, ok := strings.Cut(p, pairSep); ok {
+			f.metadata = append(f.metadata, [2]string{label, value})
+		}
+	}
+	return f
+}
+
+// values returns the field values a query term scoped to field searches:
+// "title", "id" (or "url"), "type", or a metadata label. An empty field
+// searches everything.
+func (f filterFields) values(field string) []string {
+	switch field {
+	case "":
+		values := []string{f.title, f.id, f.itemType}
+		for _, m := range f.metadata {
+			values = append(values, m[1])
+		}
+		return values
+	case "title":
+		return []string{f.title}
+	case "id", "url":
+		return []string{f.id}
+	case "type":
+		return []string{f.itemType}
+	}
+	var values []string
+	for _, m := range f.metadata {
+		if strings.EqualFold(m[0], field) {
+			values = append(values, m[1])
+		}
+	}
+	return values
+}
+
+// queryTerm is one whitespace-separated part of a filter query. Patterns
+// containing * are compiled to glob.
+type queryTerm struct {
+	field   string
+	pattern string
+	glob    *regexp.Regexp
+}
+
+func parseQuery(query string) []queryTerm {
+	var terms []queryTerm
+	for _, word := range strings.Fields(query) {
+		t := queryTerm{pattern: word}
+		if field, pattern, ok := strings.Cut(word, ":"); ok && field != "" && pattern != "" && !strings.HasPrefix(pattern, "//") {
+			t = queryTerm{field: strings.ToLower(field), pattern: pattern}
+		}
+		if strings.Contains(t.pattern, "*") {
+			expr := strings.ReplaceAll(regexp.QuoteMeta(t.pattern), `\*`, ".*")
+			t.glob = regexp.MustCompile("(?is)^" + expr + "$")
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// match reports whether the term matches one of values, with a score
+// (higher is better) and, for a fuzzy match against the title, the matched
+// rune positions.
+func (t queryTerm) match(values []string, title string) (bool, int, []int) {
+	// Globs are case-insensitive and must match the whole value
+	if t.glob != nil {
+		for _, v := range values {
+			if t.glob.MatchString(v) {
+				return true, 0, nil
+			}
+		}
+		return false, 0, nil
+	}
+
+	best, found := 0, false
+	var positions []int
+	for _, v := range values {
+		matches := fuzzy.Find(t.pattern, []string{v})
+		if len(matches) == 0 {
+			continue
+		}
+		if !found || matches[0].Score > best {
+			best = matches[0].Score
+			positions = nil
+			if v == title {
+				positions = runeIndexes(v, matches[0].MatchedIndexes)
+			}
+		}
+		found = true
+	}
+	return found, best, positions
+}
+
+// Filter is a list.FilterFunc that fuzzy matches every term of the query
+// against an item's title, id, type and metadata values. Terms such as
+// "type:collection" or "date:18*" are scoped to one field, and terms
+// containing * are matched as globs.
+func Filter(query string, targets []string) []list.Rank {
+	terms := parseQuery(query)
+	type ranked struct {
+		list.Rank
+		score int
+	}
+	var results []ranked
+	for i, target := range targets {
+		// Section headers have nothing to match
+		if target == "" {
+			continue
+		}
+		fields := parseFilterValue(target)
+		r := ranked{Rank: list.Rank{Index: i}}
+		ok := true
+		for _, t := range terms {
+			matched, score, positions := t.match(fields.values(t.field), fields.title)
+			if !matched {
+				ok = false
+				break
+			}
+			r.score += score
+			r.MatchedIndexes = append(r.MatchedIndexes, positions...)
+		}
+		if ok {
+			r.MatchedIndexes = uniqueSorted(r.MatchedIndexes)
+			results = append(results, r)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+	ranks := make([]list.Rank, len(results))
+	for i, r := range results {
+		ranks[i] = r.Rank
+	}
+	return ranks
+}
+
+func uniqueSorted(ints []int) []int {
+	sort.Ints(ints)
+	out := ints[:0]
+	for i, n := range ints {
+		if i == 0 || n != ints[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// runeIndexes converts fuzzy's byte offsets in s to rune positions.
+func runeIndexes(s string, offsets []int) []int {
+	positions := make([]int, len(offsets))
+	for i, o := range offsets {
+		positions[i] = utf8.RuneCountInString(s[:o])
+	}
+	return positions
+}
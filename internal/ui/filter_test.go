@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{Title: "Maps of Chicago", URL: "https://example.org/iiif/maps", ItemType: "Collection"},
+		{Title: "Chicago river", URL: "https://example.org/iiif/river.json", ItemType: "Manifest",
+			Metadata: map[string]string{"Date": "1871"}},
+		{Title: "Plan de Montréal", URL: "https://example.ca/iiif/montreal", ItemType: "Manifest",
+			Metadata: map[string]string{"Date": "1912", "Creator": "Ziegler"}},
+	}
+	targets := make([]string, len(items))
+	for i, item := range items {
+		targets[i] = item.FilterValue()
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int   // Matching items, best first
+		marks [][]int // Highlighted title runes of each match, if checked
+	}{
+		{name: "type field", query: "type:collection", want: []int{0}},
+		{name: "metadata glob", query: "date:18*", want: []int{1}},
+		{name: "glob on any field", query: "*.json", want: []int{1}},
+		{name: "url with scheme is not a field", query: "https://example.ca", want: []int{2}},
+		{name: "unknown field matches nothing", query: "color:red", want: nil},
+		{
+			name:  "non-ASCII title highlights runes",
+			query: "title:réal",
+			want:  []int{2},
+			marks: [][]int{{12, 13, 14, 15}},
+		},
+		{
+			name:  "metadata-only match has no title highlight",
+			query: "ziegler",
+			want:  []int{2},
+			marks: [][]int{nil},
+		},
+		{name: "every term must match", query: "chicago type:manifest", want: []int{1}},
+		{name: "better title match ranks first", query: "chicago river", want: []int{1}},
+		{name: "closer match ranks first", query: "chicago", want: []int{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks := Filter(tt.query, targets)
+			var got []int
+			for _, r := range ranks {
+				got = append(got, r.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Filter(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i, marks := range tt.marks {
+				if len(ranks[i].MatchedIndexes) == 0 && len(marks) == 0 {
+					continue
+				}
+				if !reflect.DeepEqual(ranks[i].MatchedIndexes, marks) {
+					t.Errorf("highlights = %v, want %v", ranks[i].MatchedIndexes, marks)
+				}
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		field   string
+		pattern string
+		glob    bool
+	}{
+		{"type:collection", "type", "collection", false},
+		{"Date:18*", "date", "18*", true},
+		{"https://example.org/x", "", "https://example.org/x", false},
+		{"river", "", "river", false},
+		{":odd", "", ":odd", false},
+	}
+	for _, tt := range tests {
+		terms := parseQuery(tt.query)
+		if len(terms) != 1 {
+			t.Fatalf("%q: %d terms", tt.query, len(terms))
+		}
+		term := terms[0]
+		if term.field != tt.field || term.pattern != tt.pattern || (term.glob != nil) != tt.glob {
+			t.Errorf("%q: field %q, pattern %q, glob %v", tt.query, term.field, term.pattern, term.glob != nil)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package ui
 
 // ItemType can be "Manifest", "Collection", or something else if needed.
+// Metadata maps labels to values once the item's metadata has been loaded.
 type Item struct {
 	URL      string
 	Title    string
 	ItemType string
+	Metadata map[string]string
+
+	// Parent is the label of the collection the item is listed in, NavDate
+	// its navDate and Canvases its number of canvases, 0 until known. Order
+	// is its position as listed, to return to after sorting.
+	Parent   string
+	NavDate  string
+	Canvases int
+	Order    int
 }
 
 func (i Item) TitleText() string   { return i.Title }
 func (i Item) Description() string { return i.URL }
-func (i Item) FilterValue() string { return i.Title }
+func (i Item) FilterValue() string { return i.filterValue() }
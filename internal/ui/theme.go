@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultTheme adapts to the terminal's background.
+const DefaultTheme = "auto"
+
+// Theme is the set of colors the TUI is drawn with.
+type Theme struct {
+	Name string
+
+	Accent  lipgloss.TerminalColor // Titles, focused borders and list items
+	Focus   lipgloss.TerminalColor // The title while the URL input has the focus
+	Border  lipgloss.TerminalColor // Unfocused borders
+	Muted   lipgloss.TerminalColor // Help, tool calls, URLs and other secondary text
+	Text    lipgloss.TerminalColor // Emphasized plain text
+	Success lipgloss.TerminalColor // Assistant replies and added values
+	Warning lipgloss.TerminalColor // Chat setup problems
+	Danger  lipgloss.TerminalColor // Removed values
+	Link    lipgloss.TerminalColor // Citations
+
+	// NoColor draws without any color, for NO_COLOR; thumbnails are left
+	// out since they are nothing but color.
+	NoColor bool
+}
+
+// colors names the theme's colors for theme files.
+func (t *Theme) colors() map[string]*lipgloss.TerminalColor {
+	return map[string]*lipgloss.TerminalColor{
+		"accent":  &t.Accent,
+		"focus":   &t.Focus,
+		"border":  &t.Border,
+		"muted":   &t.Muted,
+		"text":    &t.Text,
+		"success": &t.Success,
+		"warning": &t.Warning,
+		"danger":  &t.Danger,
+		"link":    &t.Link,
+	}
+}
+
+// palette is a theme's colors as ANSI 256 numbers or hex values, in the
+// order of the Theme fields.
+type palette [9]string
+
+var (
+	darkPalette       = palette{"205", "206", "240", "241", "252", "42", "214", "203", "75"}
+	lightPalette      = palette{"162", "161", "248", "243", "236", "28", "130", "160", "25"}
+	highContrastDark  = palette{"11", "14", "15", "15", "15", "10", "11", "9", "14"}
+	highContrastLight = palette{"4", "5", "0", "0", "0", "2", "1", "1", "4"}
+)
+
+// BuiltinThemes are the names of the themes that come with LoamIIIF.
+var BuiltinThemes = []string{"auto", "dark", "light", "high-contrast"}
+
+func (p palette) theme(name string) Theme {
+	t := Theme{Name: name}
+	for i, c := range t.fields() {
+		*c = lipgloss.Color(p[i])
+	}
+	return t
+}
+
+// adaptive picks light or dark by the terminal's background.
+func adaptive(name string, light, dark palette) Theme {
+	t := Theme{Name: name}
+	for i, c := range t.fields() {
+		*c = lipgloss.AdaptiveColor{Light: light[i], Dark: dark[i]}
+	}
+	return t
+}
+
+func (t *Theme) fields() []*lipgloss.TerminalColor {
+	return []*lipgloss.TerminalColor{&t.Accent, &t.Focus, &t.Border, &t.Muted, &t.Text, &t.Success, &t.Warning, &t.Danger, &t.Link}
+}
+
+// BuiltinTheme returns one of the themes that come with LoamIIIF.
+func BuiltinTheme(name string) (Theme, bool) {
+	switch name {
+	case "auto", "":
+		return adaptive("auto", lightPalette, darkPalette), true
+	case "dark":
+		return darkPalette.theme("dark"), true
+	case "light":
+		return lightPalette.theme("light"), true
+	case "high-contrast":
+		return adaptive("high-contrast", highContrastLight, highContrastDark), true
+	}
+	return Theme{}, false
+}
+
+// NoColorTheme draws without color.
+func NoColorTheme() Theme {
+	t := Theme{Name: "no-color", NoColor: true}
+	for _, c := range t.fields() {
+		*c = lipgloss.NoColor{}
+	}
+	return t
+}
+
+// themeFile is a user theme: colors replacing some of a built-in theme's.
+// Each color is a string, or an object with "light" and "dark" strings to
+// adapt to the terminal's background.
+type themeFile struct {
+	Base   string                     `json:"base"`
+	Colors map[string]json.RawMessage `json:"colors"`
+}
+
+// LoadTheme returns the built-in theme with the given name, or the user
+// theme in themes/<name>.json in the config dir or at the given path.
+func LoadTheme(name string) (Theme, error) {
+	if t, ok := BuiltinTheme(name); ok {
+		return t, nil
+	}
+
+	path := name
+	if !strings.HasSuffix(name, ".json") {
+		dir, err := config.Path("themes")
+		if err != nil {
+			return Theme{}, err
+		}
+		path = filepath.Join(dir, name+".json")
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Theme{}, fmt.Errorf("no theme named %q (built-in themes are %s)", name, strings.Join(BuiltinThemes, ", "))
+	}
+	if err != nil {
+		return Theme{}, fmt.Errorf("failed to read theme: %w", err)
+	}
+
+	var file themeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	t, ok := BuiltinTheme(file.Base)
+	if !ok {
+		return Theme{}, fmt.Errorf("%s: base must be a built-in theme, not %q", path, file.Base)
+	}
+	t.Name = strings.TrimSuffix(filepath.Base(name), ".json")
+
+	colors := t.colors()
+	names := make([]string, 0, len(file.Colors))
+	for n := range file.Colors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		c, ok := colors[n]
+		if !ok {
+			return Theme{}, fmt.Errorf("%s: unknown color %q", path, n)
+		}
+		if *c, err = parseColor(file.Colors[n]); err != nil {
+			return Theme{}, fmt.Errorf("%s: color %q: %w", path, n, err)
+		}
+	}
+	return t, nil
+}
+
+// parseColor reads "205", "#ff5f87" or {"light": "...", "dark": "..."}.
+func parseColor(raw json.RawMessage) (lipgloss.TerminalColor, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return lipgloss.Color(s), nil
+	}
+	var a struct {
+		Light string `json:"light"`
+		Dark  string `json:"dark"`
+	}
+	if err := json.Unmarshal(raw, &a); err != nil || a.Light == "" || a.Dark == "" {
+		return nil, fmt.Errorf("want a color string or {\"light\": ..., \"dark\": ...}")
+	}
+	return lipgloss.AdaptiveColor{Light: a.Light, Dark: a.Dark}, nil
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Thumbnail renders an image in at most width by height terminal cells. Each
+// cell is a half block showing two pixels, one above the other, so that the
+// roughly 1:2 cells keep the image's proportions.
+func Thumbnail(img image.Image, width, height int) string {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 || width < 1 || height < 1 {
+		return ""
+	}
+
+	// Fit the image into width x 2*height pixels
+	cols, rows := width, width*b.Dy()/b.Dx()
+	if rows > 2*height {
+		rows = 2 * height
+		cols = rows * b.Dx() / b.Dy()
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 2 {
+		rows = 2
+	}
+
+	lines := make([]string, 0, rows/2)
+	for y := 0; y+1 < rows; y += 2 {
+		var line strings.Builder
+		for x := 0; x < cols; x++ {
+			top := averageColor(img, cols, rows, x, y)
+			bottom := averageColor(img, cols, rows, x, y+1)
+			line.WriteString(lipgloss.NewStyle().Foreground(top).Background(bottom).Render("▀"))
+		}
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// averageColor averages the pixels of img that fall in cell (x, y) when it
+// is divided into cols x rows cells.
+func averageColor(img image.Image, cols, rows, x, y int) lipgloss.Color {
+	b := img.Bounds()
+	x0, x1 := b.Min.X+x*b.Dx()/cols, b.Min.X+(x+1)*b.Dx()/cols
+	y0, y1 := b.Min.Y+y*b.Dy()/rows, b.Min.Y+(y+1)*b.Dy()/rows
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	var r, g, bl, n uint64
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			cr, cg, cb, _ := img.At(px, py).RGBA()
+			r, g, bl = r+uint64(cr), g+uint64(cg), bl+uint64(cb)
+			n++
+		}
+	}
+	return lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", r/n>>8, g/n>>8, bl/n>>8))
+}
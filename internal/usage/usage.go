@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bmquinn/loam-iiif/internal/config"
+)
+
+// Record is one model request in the usage log.
+type Record struct {
+	Time         time.Time `json:"time"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	// Estimated is set when the provider did not report token counts and
+	// they were estimated from the text sent and received.
+	Estimated bool  `json:"estimated,omitempty"`
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+// Log appends records to a JSONL file. It is safe for concurrent use.
+type Log struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the location of the usage log in the config directory.
+func DefaultPath() (string, error) {
+	return config.Path("usage.jsonl")
+}
+
+// Append writes a record to the end of the log.
+func (l *Log) Append(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create usage log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load reads every record in the log at path. A missing log has no records.
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		// Skip lines cut short if loam-iiif was killed mid-write
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log: %w", err)
+	}
+	return records, nil
+}
+
+// Summary totals the requests for one day and model.
+type Summary struct {
+	Day          string // YYYY-MM-DD in local time
+	Model        string
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	Estimated    int // Requests whose token counts were estimated
+	LatencyMS    int64
+
+	// Cost is the price of the tokens, if Priced is set.
+	Cost   float64
+	Priced bool
+}
+
+// Summarize groups records since the given time (zero for all) by day and
+// model, pricing them from prices where a model has an entry.
+func Summarize(records []Record, since time.Time, prices map[string]config.Price) []Summary {
+	byKey := make(map[[2]string]*Summary)
+	for _, r := range records {
+		if r.Time.Before(since) {
+			continue
+		}
+		key := [2]string{r.Time.Local().Format("2006-01-02"), r.Model}
+		s, ok := byKey[key]
+		if !ok {
+			s = &Summary{Day: key[0], Model: key[1]}
+			byKey[key] = s
+		}
+		s.Requests++
+		s.InputTokens += r.InputTokens
+		s.OutputTokens += r.OutputTokens
+		s.LatencyMS += r.LatencyMS
+		if r.Estimated {
+			s.Estimated++
+		}
+	}
+
+	summaries := make([]Summary, 0, len(byKey))
+	for _, s := range byKey {
+		if price, ok := prices[s.Model]; ok {
+			s.Cost = price.Cost(s.InputTokens, s.OutputTokens)
+			s.Priced = true
+		}
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Day != summaries[j].Day {
+			return summaries[i].Day < summaries[j].Day
+		}
+		return summaries[i].Model < summaries[j].Model
+	})
+	return summaries
+}